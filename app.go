@@ -1,11 +1,13 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
+	"fmt"
 	"os"
 
 	"github.com/CapsLock-Studio/binance-premium-bot/models"
-	"go.uber.org/ratelimit"
+	"github.com/sirupsen/logrus"
 
 	m "github.com/CapsLock-Studio/binance-premium-bot/modules"
 )
@@ -18,25 +20,70 @@ func main() {
 	total := flag.Float64("total", 0, "total quantity")
 	reduce := flag.Bool("reduce", false, "use reduce mode")
 	arbitrage := flag.Bool("arbitrage", false, "use arbitrage mode")
-	difference := flag.Float64("difference", m.DEFAULT_DIFFERENCE, "BUSD & USDT difference")
+	spot := flag.Bool("spot", false, "use spot & perp hedge mode")
+	dryRun := flag.Bool("dryRun", false, "simulate orders against live order book")
+	difference := flag.Float64("difference", m.DEFAULT_DIFFERENCE, "mark price difference between quote pair")
 	leverage := flag.Int("leverage", 10, "futures leverage")
 	config := flag.String("config", "", "yaml config for multi-assets")
 	serve := flag.Bool("serve", false, "serve in http mode")
 	threshold := flag.Float64("threshold", 0, "minimum threshold")
 	before := flag.Float64("before", m.DEFAULT_MINUTES, "change direction before n minutes")
 	webhook := flag.String("webhook", "", "notify via webhook")
+	primaryQuote := flag.String("primaryQuote", m.DEFAULT_PRIMARY_QUOTE, "primary quote asset")
+	secondaryQuote := flag.String("secondaryQuote", m.DEFAULT_SECONDARY_QUOTE, "secondary quote asset")
+	protect := flag.Float64("protect", 0, "minimum distance to liquidation price in percent")
+	protectAction := flag.String("protectAction", m.PROTECT_ACTION_REDUCE, "reduce or margin when a leg is close to liquidation")
+	fundingFeed := flag.String("fundingFeed", "", "fallback funding rate feed when binance premium index fails, e.g. "+m.FUNDING_RATE_ENDPOINT)
+	stream := flag.Bool("stream", false, "read order book and mark price from websocket streams")
+	userStream := flag.Bool("userStream", false, "listen to order, account and margin call events of the account")
+	recvWindow := flag.Int("recvWindow", m.DEFAULT_RECV_WINDOW, "milliseconds a signed request stays valid, up to 60000")
+	shutdown := flag.String("shutdown", m.SHUTDOWN_KEEP, "keep or unwind positions on SIGINT/SIGTERM")
 	store := flag.String("store", "/data/database.db", "store data in sqlite")
+	backtest := flag.String("backtest", "", "replay recorded snapshots (json lines) instead of trading")
+	fee := flag.Float64("fee", m.SIMULATED_FEE, "taker fee rate used by backtest")
+	auth := flag.String("auth", m.AUTH_TOKEN, "authenticate http requests by token, hmac, jwt or proxy (trusts X-USER)")
+	jwtSecret := flag.String("jwtSecret", "", "HS256 secret for jwt auth")
+	jwtPublicKey := flag.String("jwtPublicKey", "", "path of the RS256 PEM public key for jwt auth")
+	proxyAdmins := flag.String("proxyAdmins", "", "comma separated users that are admins with proxy auth")
+	createToken := flag.String("createToken", "", "print a new api token of the user and exit")
+	admin := flag.Bool("admin", false, "create the token with the admin role")
 	flag.Parse()
 
-	ratelimiter := ratelimit.New(1)
+	limiter := m.NewLimiter()
+	ctx := m.NotifyShutdown()
 
-	if *serve {
+	if *createToken != "" {
 		db := m.NewDB(*store, os.Getenv("SECRET"))
 		defer db.Close()
 
-		m.NewHttp(db, ratelimiter).Serve()
+		role := m.ROLE_USER
+
+		if *admin {
+			role = m.ROLE_ADMIN
+		}
+
+		token, err := db.CreateToken(*createToken, role)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		fmt.Println(token)
+	} else if *serve {
+		db := m.NewDB(*store, os.Getenv("SECRET"))
+		defer db.Close()
+
+		authenticator, err := m.NewAuthenticator(*auth, db, m.AuthConfig{
+			JwtSecret:    *jwtSecret,
+			JwtPublicKey: *jwtPublicKey,
+			ProxyAdmins:  *proxyAdmins,
+		})
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		m.NewHttp(db, limiter, authenticator).Serve(ctx)
 	} else if *config != "" {
-		m.NewYaml(*config, ratelimiter).Run()
+		m.NewYaml(*config, limiter).Run(ctx)
 	} else {
 		setting := &models.ConfigSetting{
 			Symbol:    *symbol,
@@ -44,6 +91,8 @@ func main() {
 			Total:     *total,
 			Reduce:    *reduce,
 			Arbitrage: *arbitrage,
+			Spot:      *spot,
+			DryRun:    *dryRun,
 		}
 
 		setting.Difference = *difference
@@ -53,7 +102,33 @@ func main() {
 		setting.ApiKey = *apiKey
 		setting.ApiSecret = *apiSecret
 		setting.Webhook = *webhook
+		setting.PrimaryQuote = *primaryQuote
+		setting.SecondaryQuote = *secondaryQuote
+		setting.Protect = *protect
+		setting.ProtectAction = *protectAction
+		setting.FundingFeed = *fundingFeed
+		setting.Stream = *stream
+		setting.UserStream = *userStream
+		setting.RecvWindow = *recvWindow
+		setting.Shutdown = *shutdown
+
+		if *backtest != "" {
+			snapshots, err := m.ReadSnapshots(*backtest)
+			if err != nil {
+				logrus.Fatal(err)
+			}
+
+			result, err := m.NewBacktest(setting, snapshots, *fee).Run()
+			if err != nil {
+				logrus.Fatal(err)
+			}
+
+			output, _ := json.MarshalIndent(result, "", "  ")
+			fmt.Println(string(output))
+
+			return
+		}
 
-		m.NewCore(setting, nil, nil, ratelimiter).Run()
+		m.NewBinanceCore(setting, nil, limiter, nil).Run(ctx)
 	}
 }
@@ -0,0 +1,16 @@
+package models
+
+// User is the authenticated caller of the http API.
+type User struct {
+	ID   string
+	Role string
+}
+
+// Token is an API token of a user, its secret is only shown once on creation.
+type Token struct {
+	ID        string `json:"id"`
+	UserID    string `json:"userId"`
+	Role      string `json:"role"`
+	Hash      string `json:"-"`
+	CreatedAt int64  `json:"createdAt"`
+}
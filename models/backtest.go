@@ -0,0 +1,51 @@
+package models
+
+import (
+	binance "github.com/CapsLock-Studio/binance-premium-index/models"
+)
+
+// Snapshot is one recorded tick of hedges and order books.
+type Snapshot struct {
+	Time  int64                  `json:"time"`
+	Hedge []binance.BinanceHedge `json:"hedge"`
+	Depth map[string]Depth       `json:"depth"`
+}
+
+// Depth is the level Core trades against, the same values Exchange.GetDepth returns.
+type Depth struct {
+	Bid     float64 `json:"bid"`
+	BidSize float64 `json:"bidSize"`
+	Ask     float64 `json:"ask"`
+	AskSize float64 `json:"askSize"`
+}
+
+// GetTime falls back to the premium index time when the snapshot has none.
+func (s Snapshot) GetTime() int64 {
+	if s.Time > 0 {
+		return s.Time
+	}
+
+	for _, h := range s.Hedge {
+		for _, i := range h.Index {
+			if i.Time > 0 {
+				return int64(i.Time)
+			}
+		}
+	}
+
+	return 0
+}
+
+type BacktestPoint struct {
+	Time int64   `json:"time"`
+	Pnl  float64 `json:"pnl"`
+}
+
+type BacktestResult struct {
+	Curve     []BacktestPoint `json:"curve"`
+	Reversals int             `json:"reversals"`
+	Orders    int             `json:"orders"`
+	Fees      float64         `json:"fees"`
+	Funding   float64         `json:"funding"`
+	Pnl       float64         `json:"pnl"`
+}
@@ -1,15 +1,168 @@
 package models
 
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
 type BinancePlaceOrder struct {
-	Type       string `json:"type"`
-	Symbol     string `json:"symbol"`
-	Side       string `json:"side"`
-	Quantity   string `json:"quantity"`
-	ReduceOnly string `json:"reduceOnly"`
+	Type             string `json:"type"`
+	Symbol           string `json:"symbol"`
+	Side             string `json:"side"`
+	Quantity         string `json:"quantity"`
+	ReduceOnly       string `json:"reduceOnly"`
+	NewOrderRespType string `json:"newOrderRespType,omitempty"`
 }
 
 type BinanceOrder struct {
-	Symbol       string `json:"symbol"`
-	PositionSide string `json:"positionSide"`
-	PositionAmt  string `json:"positionAmt"`
+	Symbol           string `json:"symbol"`
+	PositionSide     string `json:"positionSide"`
+	PositionAmt      string `json:"positionAmt"`
+	EntryPrice       string `json:"entryPrice"`
+	MarkPrice        string `json:"markPrice"`
+	LiquidationPrice string `json:"liquidationPrice"`
+	MarginType       string `json:"marginType"`
+	IsolatedMargin   string `json:"isolatedMargin"`
+}
+
+type BinanceBalance struct {
+	Asset              string `json:"asset"`
+	Balance            string `json:"balance"`
+	AvailableBalance   string `json:"availableBalance"`
+	CrossWalletBalance string `json:"crossWalletBalance"`
+}
+
+type BinanceSpotBalance struct {
+	Asset  string `json:"asset"`
+	Free   string `json:"free"`
+	Locked string `json:"locked"`
+}
+
+type BinanceAccount struct {
+	TotalMaintMargin   string `json:"totalMaintMargin"`
+	TotalMarginBalance string `json:"totalMarginBalance"`
+}
+
+// BinanceError is the {code,msg} body of a failed request, Status is the HTTP status.
+type BinanceError struct {
+	Status int    `json:"-"`
+	Code   int    `json:"code"`
+	Msg    string `json:"msg"`
+}
+
+func (e *BinanceError) Error() string {
+	if e.Code == 0 {
+		return fmt.Sprintf("binance status %d: %s", e.Status, e.Msg)
+	}
+
+	return fmt.Sprintf("binance error %d: %s", e.Code, e.Msg)
+}
+
+// BinanceOrderResult is either a placed order or a rejection with code & msg.
+type BinanceOrderResult struct {
+	OrderID     int64          `json:"orderId"`
+	Symbol      string         `json:"symbol"`
+	Side        string         `json:"side"`
+	Status      string         `json:"status"`
+	OrigQty     string         `json:"origQty"`
+	ExecutedQty string         `json:"executedQty"`
+	AvgPrice    string         `json:"avgPrice"`
+	Fills       []BinanceTrade `json:"fills"`
+	Code        int            `json:"code"`
+	Msg         string         `json:"msg"`
+}
+
+// BinanceTrade is a futures user trade or a spot order fill.
+type BinanceTrade struct {
+	OrderID         int64  `json:"orderId"`
+	Price           string `json:"price"`
+	Qty             string `json:"qty"`
+	Commission      string `json:"commission"`
+	CommissionAsset string `json:"commissionAsset"`
+	RealizedPnl     string `json:"realizedPnl"`
+	Time            int64  `json:"time"`
+}
+
+func (r BinanceOrderResult) Rejected() bool {
+	return r.Code != 0 || r.OrderID == 0
+}
+
+type BinanceFilter struct {
+	FilterType  string `json:"filterType"`
+	StepSize    string `json:"stepSize"`
+	MinQty      string `json:"minQty"`
+	MaxQty      string `json:"maxQty"`
+	TickSize    string `json:"tickSize"`
+	Notional    string `json:"notional"`
+	MinNotional string `json:"minNotional"`
+}
+
+type BinanceSymbolInfo struct {
+	Symbol  string          `json:"symbol"`
+	Status  string          `json:"status"`
+	Filters []BinanceFilter `json:"filters"`
+}
+
+func (s *BinanceSymbolInfo) GetFilter(filterType string) *BinanceFilter {
+	for i := range s.Filters {
+		if s.Filters[i].FilterType == filterType {
+			return &s.Filters[i]
+		}
+	}
+
+	return nil
+}
+
+// RoundQuantity floors quantity to the lot size step.
+func (s *BinanceSymbolInfo) RoundQuantity(quantity decimal.Decimal) decimal.Decimal {
+	filter := s.GetFilter("LOT_SIZE")
+
+	if filter == nil {
+		return quantity
+	}
+
+	step, _ := decimal.NewFromString(filter.StepSize)
+
+	if step.IsZero() {
+		return quantity
+	}
+
+	return quantity.Div(step).Floor().Mul(step)
+}
+
+func (s *BinanceSymbolInfo) GetMinQty() decimal.Decimal {
+	var minQty decimal.Decimal
+
+	if filter := s.GetFilter("LOT_SIZE"); filter != nil {
+		minQty, _ = decimal.NewFromString(filter.MinQty)
+	}
+
+	return minQty
+}
+
+// GetMinNotional reads MIN_NOTIONAL of futures or NOTIONAL of spot.
+func (s *BinanceSymbolInfo) GetMinNotional() decimal.Decimal {
+	var minNotional decimal.Decimal
+
+	if filter := s.GetFilter("MIN_NOTIONAL"); filter != nil {
+		if filter.Notional != "" {
+			minNotional, _ = decimal.NewFromString(filter.Notional)
+		} else {
+			minNotional, _ = decimal.NewFromString(filter.MinNotional)
+		}
+	} else if filter := s.GetFilter("NOTIONAL"); filter != nil {
+		minNotional, _ = decimal.NewFromString(filter.MinNotional)
+	}
+
+	return minNotional
+}
+
+type BinanceIncome struct {
+	Symbol     string `json:"symbol"`
+	IncomeType string `json:"incomeType"`
+	Income     string `json:"income"`
+	Asset      string `json:"asset"`
+	Time       int64  `json:"time"`
+	TranID     int64  `json:"tranId"`
 }
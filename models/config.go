@@ -1,23 +1,93 @@
 package models
 
 type BaseConfig struct {
-	ApiKey     string  `yaml:"apiKey" json:"apiKey"`
-	ApiSecret  string  `yaml:"apiSecret" json:"apiSecret"`
-	Leverage   int     `yaml:"leverage" json:"leverage"`
-	Difference float64 `yaml:"difference" json:"difference"`
-	Before     float64 `yaml:"before" json:"before"`
-	Webhook    string  `yaml:"webhook" json:"webhook"`
-	Threshold  float64 `yaml:"threshold" json:"threshold"`
+	ApiKey         string  `yaml:"apiKey" json:"apiKey"`
+	ApiSecret      string  `yaml:"apiSecret" json:"apiSecret"`
+	Leverage       int     `yaml:"leverage" json:"leverage"`
+	Difference     float64 `yaml:"difference" json:"difference"`
+	Before         float64 `yaml:"before" json:"before"`
+	Webhook        string  `yaml:"webhook" json:"webhook"`
+	Threshold      float64 `yaml:"threshold" json:"threshold"`
+	PrimaryQuote   string  `yaml:"primaryQuote" json:"primaryQuote"`
+	SecondaryQuote string  `yaml:"secondaryQuote" json:"secondaryQuote"`
+	Protect        float64 `yaml:"protect" json:"protect"`
+	ProtectAction  string  `yaml:"protectAction" json:"protectAction"`
+	FundingFeed    string  `yaml:"fundingFeed" json:"fundingFeed"`
+	Stream         bool    `yaml:"stream" json:"stream"`
+	UserStream     bool    `yaml:"userStream" json:"userStream"`
+	RecvWindow     int     `yaml:"recvWindow" json:"recvWindow"`
+	Shutdown       string  `yaml:"shutdown" json:"shutdown"`
 }
 
 type ConfigSetting struct {
 	BaseConfig
-	Symbol    string  `yaml:"symbol" json:"symbol"`
-	Quantity  float64 `yaml:"quantity" json:"quantity"`
-	Total     float64 `yaml:"total" json:"total"`
-	Reduce    bool    `yaml:"reduce" json:"reduce"`
-	Arbitrage bool    `yaml:"arbitrage" json:"arbitrage"`
-	UserID    string  `json:"-"`
+	Symbol       string  `yaml:"symbol" json:"symbol"`
+	Quantity     float64 `yaml:"quantity" json:"quantity"`
+	Total        float64 `yaml:"total" json:"total"`
+	Reduce       bool    `yaml:"reduce" json:"reduce"`
+	Arbitrage    bool    `yaml:"arbitrage" json:"arbitrage"`
+	Spot         bool    `yaml:"spot" json:"spot"`
+	DryRun       bool    `yaml:"dryRun" json:"dryRun"`
+	Paused       bool    `yaml:"-" json:"paused"`
+	CredentialID string  `yaml:"-" json:"credentialId,omitempty"`
+	UserID       string  `json:"-"`
+}
+
+// SettingPatch holds the settings a running bot can change without a restart.
+type SettingPatch struct {
+	Difference    *float64 `json:"difference"`
+	Quantity      *float64 `json:"quantity"`
+	Total         *float64 `json:"total"`
+	Leverage      *int     `json:"leverage"`
+	Before        *float64 `json:"before"`
+	Threshold     *float64 `json:"threshold"`
+	Webhook       *string  `json:"webhook"`
+	Protect       *float64 `json:"protect"`
+	ProtectAction *string  `json:"protectAction"`
+	Shutdown      *string  `json:"shutdown"`
+}
+
+// Apply copies every field set in the patch to s.
+func (p SettingPatch) Apply(s *ConfigSetting) {
+	if p.Difference != nil {
+		s.Difference = *p.Difference
+	}
+
+	if p.Quantity != nil {
+		s.Quantity = *p.Quantity
+	}
+
+	if p.Total != nil {
+		s.Total = *p.Total
+	}
+
+	if p.Leverage != nil {
+		s.Leverage = *p.Leverage
+	}
+
+	if p.Before != nil {
+		s.Before = *p.Before
+	}
+
+	if p.Threshold != nil {
+		s.Threshold = *p.Threshold
+	}
+
+	if p.Webhook != nil {
+		s.Webhook = *p.Webhook
+	}
+
+	if p.Protect != nil {
+		s.Protect = *p.Protect
+	}
+
+	if p.ProtectAction != nil {
+		s.ProtectAction = *p.ProtectAction
+	}
+
+	if p.Shutdown != nil {
+		s.Shutdown = *p.Shutdown
+	}
 }
 
 type Config struct {
@@ -0,0 +1,25 @@
+package models
+
+// Credential is a named Binance key pair of a user, bots reference it by ID.
+type Credential struct {
+	ID        string `json:"id"`
+	UserID    string `json:"userId"`
+	Name      string `json:"name"`
+	ApiKey    string `json:"apiKey"`
+	ApiSecret string `json:"apiSecret"`
+	CreatedAt int64  `json:"createdAt"`
+	UpdatedAt int64  `json:"updatedAt"`
+}
+
+// Masked hides all but the last 4 characters of the secret.
+func (c Credential) Masked() Credential {
+	masked := "****"
+
+	if len(c.ApiSecret) > 8 {
+		masked += c.ApiSecret[len(c.ApiSecret)-4:]
+	}
+
+	c.ApiSecret = masked
+
+	return c
+}
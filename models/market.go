@@ -0,0 +1,12 @@
+package models
+
+import (
+	binance "github.com/CapsLock-Studio/binance-premium-index/models"
+)
+
+// MarketData is what the market data hub fans out to each subscriber.
+type MarketData struct {
+	Time     int64
+	Premiums []binance.BinancePremium
+	Depth    map[string]Depth
+}
@@ -1,7 +1,20 @@
 package models
 
+// EventMessage carries Symbol and Webhook by value, the setting they come
+// from can be updated while the event waits to be sent.
 type EventMessage struct {
 	Type    string
-	Setting *ConfigSetting
+	Symbol  string
+	Webhook string
 	Message any
 }
+
+// BotEvent is an EventMessage as webhooks and event streams see it.
+type BotEvent struct {
+	Type    string `json:"type"`
+	ID      string `json:"id"`
+	Symbol  string `json:"symbol"`
+	Message any    `json:"message"`
+	UserID  string `json:"userId"`
+	Time    int64  `json:"time"`
+}
@@ -0,0 +1,18 @@
+package models
+
+// CoreState is the loop state of a running bot, checkpointed so it can resume.
+type CoreState struct {
+	CurrentDirection        *bool   `json:"currentDirection"`
+	FundingRateReverseMode  bool    `json:"fundingRateReverseMode"`
+	ArbitrageDirection      *bool   `json:"arbitrageDirection"`
+	ArbitrageTriggered      bool    `json:"arbitrageTriggered"`
+	Step                    int     `json:"step"`
+	CurrentProgressBarTotal int     `json:"currentProgressBarTotal"`
+	MaxProgressBar          int     `json:"maxProgressBar"`
+	TotalQuantity           float64 `json:"totalQuantity"`
+	Total                   float64 `json:"total"`
+	Reduce                  bool    `json:"reduce"`
+	Hedged                  float64 `json:"hedged"`
+	Unwinding               bool    `json:"unwinding"`
+	ShutdownUnwind          bool    `json:"shutdownUnwind"`
+}
@@ -0,0 +1,30 @@
+package models
+
+// BotStatus is a snapshot of a running bot, taken by its loop after every tick.
+type BotStatus struct {
+	ID                      string  `json:"id"`
+	Symbol                  string  `json:"symbol"`
+	PrimaryQuote            string  `json:"primaryQuote"`
+	SecondaryQuote          string  `json:"secondaryQuote"`
+	Spot                    bool    `json:"spot"`
+	DryRun                  bool    `json:"dryRun"`
+	Paused                  bool    `json:"paused"`
+	Reduce                  bool    `json:"reduce"`
+	Arbitrage               bool    `json:"arbitrage"`
+	Direction               *bool   `json:"direction"`
+	Total                   float64 `json:"total"`
+	TotalQuantity           float64 `json:"totalQuantity"`
+	Step                    int     `json:"step"`
+	CurrentProgressBarTotal int     `json:"currentProgressBarTotal"`
+	MaxProgressBar          int     `json:"maxProgressBar"`
+	Hedged                  float64 `json:"hedged"`
+	Reverse                 bool    `json:"reverse"`
+	ArbitrageTriggered      bool    `json:"arbitrageTriggered"`
+	Unwinding               bool    `json:"unwinding"`
+	MarkPriceGap            float64 `json:"markPriceGap"`
+	FundingRateGap          float64 `json:"fundingRateGap"`
+	LastOrderTime           int64   `json:"lastOrderTime"`
+	LastError               string  `json:"lastError"`
+	LastErrorTime           int64   `json:"lastErrorTime"`
+	UpdatedAt               int64   `json:"updatedAt"`
+}
@@ -0,0 +1,42 @@
+package models
+
+// Trade is one filled leg of a batch placed by a bot.
+type Trade struct {
+	ID          string  `json:"id"`
+	BatchID     string  `json:"batchId"`
+	BotID       string  `json:"botId"`
+	UserID      string  `json:"userId"`
+	Type        string  `json:"type"`
+	Symbol      string  `json:"symbol"`
+	OrderID     int64   `json:"orderId"`
+	Side        string  `json:"side"`
+	Quantity    float64 `json:"quantity"`
+	Price       float64 `json:"price"`
+	Fee         float64 `json:"fee"`
+	FeeAsset    string  `json:"feeAsset"`
+	RealizedPnl float64 `json:"realizedPnl"`
+	CreatedAt   int64   `json:"createdAt"`
+}
+
+// Income is a funding fee or commission attributed to a bot.
+type Income struct {
+	BotID  string  `json:"botId"`
+	UserID string  `json:"userId"`
+	Symbol string  `json:"symbol"`
+	Type   string  `json:"type"`
+	Income float64 `json:"income"`
+	Asset  string  `json:"asset"`
+	Time   int64   `json:"time"`
+	TranID int64   `json:"tranId"`
+}
+
+type Stats struct {
+	Funding     float64 `json:"funding"`
+	Fees        float64 `json:"fees"`
+	RealizedPnl float64 `json:"realizedPnl"`
+	Net         float64 `json:"net"`
+	Capital     float64 `json:"capital"`
+	Leverage    int     `json:"leverage"`
+	Days        float64 `json:"days"`
+	Apr         float64 `json:"apr"`
+}
@@ -0,0 +1,60 @@
+package models
+
+// OrderUpdate is an ORDER_TRADE_UPDATE of the user data stream.
+type OrderUpdate struct {
+	Symbol          string `json:"symbol"`
+	ClientOrderID   string `json:"clientOrderId"`
+	OrderID         int64  `json:"orderId"`
+	Side            string `json:"side"`
+	Type            string `json:"type"`
+	ExecutionType   string `json:"executionType"`
+	Status          string `json:"status"`
+	Quantity        string `json:"quantity"`
+	AvgPrice        string `json:"avgPrice"`
+	LastFilledQty   string `json:"lastFilledQty"`
+	LastFilledPrice string `json:"lastFilledPrice"`
+	FilledQty       string `json:"filledQty"`
+	Commission      string `json:"commission"`
+	CommissionAsset string `json:"commissionAsset"`
+	RealizedPnl     string `json:"realizedPnl"`
+	ReduceOnly      bool   `json:"reduceOnly"`
+	Time            int64  `json:"time"`
+}
+
+// Liquidated reports whether the exchange closed the position by itself.
+func (o OrderUpdate) Liquidated() bool {
+	return o.Type == "LIQUIDATION" || o.ExecutionType == "CALCULATED"
+}
+
+type AccountBalance struct {
+	Asset              string `json:"asset"`
+	WalletBalance      string `json:"walletBalance"`
+	CrossWalletBalance string `json:"crossWalletBalance"`
+}
+
+type AccountPosition struct {
+	Symbol         string `json:"symbol"`
+	PositionSide   string `json:"positionSide"`
+	PositionAmt    string `json:"positionAmt"`
+	EntryPrice     string `json:"entryPrice"`
+	MarkPrice      string `json:"markPrice,omitempty"`
+	UnrealizedPnl  string `json:"unrealizedPnl"`
+	MarginType     string `json:"marginType"`
+	IsolatedMargin string `json:"isolatedMargin"`
+	MaintMargin    string `json:"maintMargin,omitempty"`
+}
+
+// AccountUpdate is an ACCOUNT_UPDATE of the user data stream.
+type AccountUpdate struct {
+	Reason    string            `json:"reason"`
+	Balances  []AccountBalance  `json:"balances"`
+	Positions []AccountPosition `json:"positions"`
+	Time      int64             `json:"time"`
+}
+
+// MarginCall is a MARGIN_CALL of the user data stream.
+type MarginCall struct {
+	CrossWalletBalance string            `json:"crossWalletBalance"`
+	Positions          []AccountPosition `json:"positions"`
+	Time               int64             `json:"time"`
+}
@@ -0,0 +1,215 @@
+package modules
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+)
+
+const (
+	AUTH_TOKEN string = "token"
+	AUTH_HMAC  string = "hmac"
+	AUTH_JWT   string = "jwt"
+	AUTH_PROXY string = "proxy"
+
+	ROLE_USER  string = "user"
+	ROLE_ADMIN string = "admin"
+
+	HMAC_WINDOW time.Duration = 5 * time.Minute
+)
+
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Authenticator resolves the user of a request.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*models.User, error)
+}
+
+// TokenStore looks API tokens up by ID, nil when there is no such token.
+type TokenStore interface {
+	GetToken(ID string) (*models.Token, error)
+	TokenSecret(ID string) string
+}
+
+// AuthConfig holds the options of every kind, each kind only reads its own.
+type AuthConfig struct {
+	JwtSecret    string
+	JwtPublicKey string
+	// ProxyAdmins is a comma separated list of users that are admins with proxy auth.
+	ProxyAdmins string
+}
+
+// NewAuthenticator returns the authenticator of kind.
+func NewAuthenticator(kind string, tokens TokenStore, config AuthConfig) (Authenticator, error) {
+	switch kind {
+	case AUTH_TOKEN:
+		return &TokenAuth{Tokens: tokens}, nil
+	case AUTH_HMAC:
+		return &HmacAuth{Tokens: tokens, Window: HMAC_WINDOW}, nil
+	case AUTH_JWT:
+		auth, err := NewJwtAuth(config.JwtSecret, config.JwtPublicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		return auth, nil
+	case AUTH_PROXY:
+		return NewProxyAuth(config.ProxyAdmins), nil
+	}
+
+	return nil, fmt.Errorf("unknown auth %s, use token, hmac, jwt or proxy", kind)
+}
+
+// TokenAuth accepts "Authorization: Bearer <id>.<secret>".
+type TokenAuth struct {
+	Tokens TokenStore
+}
+
+func (a *TokenAuth) Authenticate(r *http.Request) (*models.User, error) {
+	ID, secret, ok := strings.Cut(bearer(r), ".")
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+
+	token, err := a.Tokens.GetToken(ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if token == nil || subtle.ConstantTimeCompare([]byte(token.Hash), []byte(HashSecret(secret))) != 1 {
+		return nil, ErrUnauthenticated
+	}
+
+	return &models.User{ID: token.UserID, Role: token.Role}, nil
+}
+
+// HmacAuth accepts requests signed with the secret of a token:
+// X-API-KEY is the token ID, X-TIMESTAMP the time in milliseconds and
+// X-SIGNATURE the hex HMAC-SHA256 of timestamp + method + path with query + body.
+type HmacAuth struct {
+	Tokens TokenStore
+	Window time.Duration
+}
+
+func (a *HmacAuth) Authenticate(r *http.Request) (*models.User, error) {
+	timestamp, err := strconv.ParseInt(r.Header.Get("X-TIMESTAMP"), 10, 64)
+	if err != nil {
+		return nil, ErrUnauthenticated
+	}
+
+	// limit replays of a captured request
+	if drift := time.Since(time.UnixMilli(timestamp)); drift > a.Window || drift < -a.Window {
+		return nil, ErrUnauthenticated
+	}
+
+	signature, err := hex.DecodeString(r.Header.Get("X-SIGNATURE"))
+	if err != nil {
+		return nil, ErrUnauthenticated
+	}
+
+	token, err := a.Tokens.GetToken(r.Header.Get("X-API-KEY"))
+	if err != nil {
+		return nil, err
+	}
+
+	if token == nil {
+		return nil, ErrUnauthenticated
+	}
+
+	var body []byte
+
+	if r.Body != nil {
+		body, err = io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		// handlers read the body again
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	mac := hmac.New(sha256.New, []byte(a.Tokens.TokenSecret(token.ID)))
+	mac.Write([]byte(r.Header.Get("X-TIMESTAMP") + r.Method + r.URL.RequestURI()))
+	mac.Write(body)
+
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, ErrUnauthenticated
+	}
+
+	return &models.User{ID: token.UserID, Role: token.Role}, nil
+}
+
+// ProxyAuth trusts X-USER, only for servers reachable through a proxy that sets it.
+// Roles never come from headers, a proxy may pass them through from the client.
+type ProxyAuth struct {
+	Admins map[string]bool
+}
+
+// NewProxyAuth makes the comma separated users of admins admins.
+func NewProxyAuth(admins string) *ProxyAuth {
+	auth := &ProxyAuth{Admins: map[string]bool{}}
+
+	for _, v := range strings.Split(admins, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			auth.Admins[v] = true
+		}
+	}
+
+	return auth
+}
+
+func (a *ProxyAuth) Authenticate(r *http.Request) (*models.User, error) {
+	userID := r.Header.Get("X-USER")
+
+	if userID == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	role := ROLE_USER
+
+	if a.Admins[userID] {
+		role = ROLE_ADMIN
+	}
+
+	return &models.User{ID: userID, Role: role}, nil
+}
+
+// bearer returns the bearer token of the request, empty when there is none.
+func bearer(r *http.Request) string {
+	value := r.Header.Get("Authorization")
+
+	if !strings.HasPrefix(value, "Bearer ") {
+		return ""
+	}
+
+	return strings.TrimPrefix(value, "Bearer ")
+}
+
+// HashSecret is how token secrets are kept, they're random so a plain hash is enough.
+func HashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+
+	return hex.EncodeToString(hash[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,218 @@
+package modules
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenAuth(t *testing.T) {
+	db := newTestDB(t)
+	auth, err := NewAuthenticator(AUTH_TOKEN, db, AuthConfig{})
+	assert.NoError(t, err)
+
+	token, _ := db.CreateToken("user", ROLE_USER)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	user, err := auth.Authenticate(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "user", user.ID)
+	assert.Equal(t, ROLE_USER, user.Role)
+
+	for _, value := range []string{"", "Bearer " + token + "x", "Bearer nope.nope", "Bearer " + token[:16]} {
+		r.Header.Set("Authorization", value)
+
+		_, err = auth.Authenticate(r)
+		assert.ErrorIs(t, err, ErrUnauthenticated, value)
+	}
+
+	// X-USER means nothing without the proxy option
+	r.Header.Del("Authorization")
+	r.Header.Set("X-USER", "user")
+
+	_, err = auth.Authenticate(r)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+}
+
+func TestHmacAuth(t *testing.T) {
+	db := newTestDB(t)
+	auth, _ := NewAuthenticator(AUTH_HMAC, db, AuthConfig{})
+
+	token, _ := db.CreateToken("user", ROLE_ADMIN)
+	ID, secret, _ := strings.Cut(token, ".")
+
+	request := func(timestamp int64, body string, key string) (string, error) {
+		r := httptest.NewRequest("POST", "/abc/pause?x=1", strings.NewReader(body))
+
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(fmt.Sprint(timestamp) + "POST/abc/pause?x=1" + body))
+
+		r.Header.Set("X-API-KEY", ID)
+		r.Header.Set("X-TIMESTAMP", fmt.Sprint(timestamp))
+		r.Header.Set("X-SIGNATURE", hex.EncodeToString(mac.Sum(nil)))
+
+		user, err := auth.Authenticate(r)
+		if err != nil {
+			return "", err
+		}
+
+		// the handler still reads the body
+		rest, _ := io.ReadAll(r.Body)
+		assert.Equal(t, body, string(rest))
+
+		return user.ID + ":" + user.Role, nil
+	}
+
+	now := time.Now().UnixMilli()
+
+	user, err := request(now, `{"total":1}`, secret)
+	assert.NoError(t, err)
+	assert.Equal(t, "user:admin", user)
+
+	_, err = request(now, `{"total":1}`, "wrong")
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+
+	_, err = request(now-HMAC_WINDOW.Milliseconds()-1000, `{"total":1}`, secret)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+}
+
+func TestJwtAuth(t *testing.T) {
+	_, err := NewAuthenticator(AUTH_JWT, nil, AuthConfig{})
+	assert.Error(t, err)
+
+	auth, err := NewAuthenticator(AUTH_JWT, nil, AuthConfig{JwtSecret: "key"})
+	assert.NoError(t, err)
+
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	jwt := func(alg string, claims string, key string) string {
+		payload := encode(`{"alg":"`+alg+`","typ":"JWT"}`) + "." + encode(claims)
+
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(payload))
+
+		return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	exp := time.Now().Add(time.Hour).Unix()
+
+	authenticate := func(token string) (string, error) {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+
+		user, err := auth.Authenticate(r)
+		if err != nil {
+			return "", err
+		}
+
+		return user.ID + ":" + user.Role, nil
+	}
+
+	user, err := authenticate(jwt("HS256", fmt.Sprintf(`{"sub":"user","role":"admin","exp":%d}`, exp), "key"))
+	assert.NoError(t, err)
+	assert.Equal(t, "user:admin", user)
+
+	user, err = authenticate(jwt("HS256", fmt.Sprintf(`{"sub":"user","exp":%d}`, exp), "key"))
+	assert.NoError(t, err)
+	assert.Equal(t, "user:user", user)
+
+	for _, token := range []string{
+		jwt("HS256", fmt.Sprintf(`{"sub":"user","exp":%d}`, exp), "other"),
+		jwt("none", fmt.Sprintf(`{"sub":"user","exp":%d}`, exp), "key"),
+		jwt("HS256", fmt.Sprintf(`{"sub":"user","exp":%d}`, time.Now().Add(-time.Minute).Unix()), "key"),
+		jwt("HS256", fmt.Sprintf(`{"sub":"user","exp":%d,"nbf":%d}`, exp, exp), "key"),
+		jwt("HS256", `{"sub":"user"}`, "key"),
+		"a.b",
+	} {
+		_, err = authenticate(token)
+		assert.ErrorIs(t, err, ErrUnauthenticated, token)
+	}
+}
+
+func TestProxyAuth(t *testing.T) {
+	auth, _ := NewAuthenticator(AUTH_PROXY, nil, AuthConfig{ProxyAdmins: "alice, bob"})
+
+	r := httptest.NewRequest("GET", "/", nil)
+
+	_, err := auth.Authenticate(r)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+
+	// a role header from the client is ignored
+	r.Header.Set("X-USER", "user")
+	r.Header.Set("X-ROLE", ROLE_ADMIN)
+
+	user, err := auth.Authenticate(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "user", user.ID)
+	assert.Equal(t, ROLE_USER, user.Role)
+
+	r.Header.Set("X-USER", "bob")
+
+	user, err = auth.Authenticate(r)
+	assert.NoError(t, err)
+	assert.Equal(t, ROLE_ADMIN, user.Role)
+
+	_, err = NewAuthenticator("basic", nil, AuthConfig{})
+	assert.Error(t, err)
+}
+
+func TestJwtAuthRsa(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	public, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+
+	path := filepath.Join(t.TempDir(), "jwt.pem")
+	os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}), 0600)
+
+	_, err := NewJwtAuth("", filepath.Join(t.TempDir(), "missing.pem"))
+	assert.Error(t, err)
+
+	_, err = NewJwtAuth("key", path)
+	assert.Error(t, err)
+
+	auth, err := NewJwtAuth("", path)
+	assert.NoError(t, err)
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"sub":"user","exp":%d}`, time.Now().Add(time.Hour).Unix())))
+
+	hash := sha256.Sum256([]byte(payload))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer "+payload+"."+base64.RawURLEncoding.EncodeToString(signature))
+
+	user, err := auth.Authenticate(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "user", user.ID)
+
+	// a HS256 token signed with the public key must not pass
+	payload = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256"}`)) + payload[strings.Index(payload, "."):]
+
+	mac := hmac.New(sha256.New, public)
+	mac.Write([]byte(payload))
+
+	r.Header.Set("Authorization", "Bearer "+payload+"."+base64.RawURLEncoding.EncodeToString(mac.Sum(nil)))
+
+	_, err = auth.Authenticate(r)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+}
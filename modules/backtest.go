@@ -0,0 +1,130 @@
+package modules
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/ratelimit"
+
+	binance "github.com/CapsLock-Studio/binance-premium-index/models"
+)
+
+// Backtest replays recorded snapshots through the same Tick as a live bot.
+type Backtest struct {
+	Setting   *models.ConfigSetting
+	Snapshots []models.Snapshot
+	Simulator *Simulator
+	current   []binance.BinanceHedge
+}
+
+func NewBacktest(setting *models.ConfigSetting, snapshots []models.Snapshot, fee float64) *Backtest {
+	return &Backtest{
+		Setting:   setting,
+		Snapshots: snapshots,
+		Simulator: NewSimulator(fee),
+	}
+}
+
+// ReadSnapshots reads a file with one json snapshot per line.
+func ReadSnapshots(path string) ([]models.Snapshot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	snapshots := make([]models.Snapshot, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var snapshot models.Snapshot
+
+		if err := json.Unmarshal(scanner.Bytes(), &snapshot); err != nil {
+			return nil, err
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, scanner.Err()
+}
+
+// GetHedge serves the snapshot being replayed, Backtest is the Core's provider.
+func (b *Backtest) GetHedge() ([]binance.BinanceHedge, error) {
+	return b.current, nil
+}
+
+func (b *Backtest) Run() (models.BacktestResult, error) {
+	result := models.BacktestResult{
+		Curve: make([]models.BacktestPoint, 0),
+	}
+
+	if b.Setting.Spot {
+		return result, errors.New("backtest doesn't support spot mode")
+	}
+
+	if len(b.Snapshots) == 0 {
+		return result, errors.New("no snapshot to replay")
+	}
+
+	core := NewCore(b.Setting, b.Simulator, nil, ratelimit.NewUnlimited())
+	core.Provider = b
+
+	done := make(chan bool)
+
+	go func() {
+		for v := range core.GetPublisher() {
+			if v.Type == "reverse" {
+				result.Reversals += 1
+			}
+		}
+
+		done <- true
+	}()
+
+	log := logrus.New()
+	log.SetLevel(logrus.WarnLevel)
+
+	logger := log.
+		WithField("symbol", b.Setting.Symbol).
+		WithField("pair", b.Setting.PrimaryQuote+"/"+b.Setting.SecondaryQuote)
+
+	for i, snapshot := range b.Snapshots {
+		b.Simulator.Feed(snapshot)
+		b.current = snapshot.Hedge
+
+		if i == 0 {
+			core.Prepare(logger)
+		}
+
+		running := core.Tick(logger)
+
+		result.Curve = append(result.Curve, models.BacktestPoint{
+			Time: snapshot.GetTime(),
+			Pnl:  b.Simulator.Equity(),
+		})
+
+		if !running {
+			break
+		}
+	}
+
+	close(core.EventPublisher)
+	<-done
+
+	result.Orders = b.Simulator.Orders
+	result.Fees, _ = b.Simulator.Fees.Float64()
+	result.Funding, _ = b.Simulator.Funding.Float64()
+	result.Pnl = b.Simulator.Equity()
+
+	return result, nil
+}
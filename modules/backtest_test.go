@@ -0,0 +1,77 @@
+package modules
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/stretchr/testify/assert"
+
+	binance "github.com/CapsLock-Studio/binance-premium-index/models"
+)
+
+const testFundingTime = 1700000000000
+
+func newTestSnapshot(time int64, primaryRate, secondaryRate string) models.Snapshot {
+	index := []binance.BinancePremium{
+		{Symbol: "LDOUSDT", MarkPrice: "2", LastFundingRate: primaryRate, NextFundingTime: testFundingTime, Time: int(time)},
+		{Symbol: "LDOUSDC", MarkPrice: "2", LastFundingRate: secondaryRate, NextFundingTime: testFundingTime, Time: int(time)},
+	}
+
+	return models.Snapshot{
+		Time:  time,
+		Hedge: []binance.BinanceHedge{{Symbol: "LDO", Index: index}},
+		Depth: map[string]models.Depth{
+			"LDOUSDT": {Bid: 2, BidSize: 100, Ask: 2, AskSize: 100},
+			"LDOUSDC": {Bid: 2, BidSize: 100, Ask: 2, AskSize: 100},
+		},
+	}
+}
+
+func TestBacktest(t *testing.T) {
+	setting := &models.ConfigSetting{Symbol: "LDO", Quantity: 1, Total: 4}
+	setting.Leverage = 1
+	setting.Difference = DEFAULT_DIFFERENCE
+	setting.Before = DEFAULT_MINUTES
+	setting.PrimaryQuote = "USDT"
+	setting.SecondaryQuote = "USDC"
+
+	hour := int64(60 * 60 * 1000)
+
+	snapshots := []models.Snapshot{
+		newTestSnapshot(testFundingTime-3*hour, "0.001", "0.0001"),
+		newTestSnapshot(testFundingTime-2*hour, "0.001", "0.0001"),
+		// funding settles, then the gap flips
+		newTestSnapshot(testFundingTime, "0.0001", "0.001"),
+		newTestSnapshot(testFundingTime+hour, "0.0001", "0.001"),
+	}
+
+	result, err := NewBacktest(setting, snapshots, SIMULATED_FEE).Run()
+
+	assert.NoError(t, err)
+	assert.Len(t, result.Curve, 4)
+
+	// two batches opened, both closed after the reversal
+	assert.Equal(t, 1, result.Reversals)
+	assert.Equal(t, 8, result.Orders)
+	assert.InDelta(t, 0.008, result.Fees, 1e-9)
+
+	// short USDT receives 2*2*0.001, long USDC pays 2*2*0.0001
+	assert.InDelta(t, 0.0036, result.Funding, 1e-9)
+	assert.InDelta(t, -0.0044, result.Pnl, 1e-9)
+	assert.Equal(t, result.Pnl, result.Curve[3].Pnl)
+}
+
+func TestReadSnapshots(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshots.jsonl")
+
+	os.WriteFile(path, []byte(`{"time":1,"hedge":[{"symbol":"LDO"}]}`+"\n\n"+`{"time":2,"depth":{"LDOUSDT":{"bid":1,"bidSize":2,"ask":3,"askSize":4}}}`+"\n"), 0644)
+
+	snapshots, err := ReadSnapshots(path)
+
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, 2)
+	assert.Equal(t, "LDO", snapshots[0].Hedge[0].Symbol)
+	assert.Equal(t, 4.0, snapshots[1].Depth["LDOUSDT"].AskSize)
+}
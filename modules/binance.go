@@ -0,0 +1,407 @@
+package modules
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/parnurzeal/gorequest"
+	"github.com/shopspring/decimal"
+
+	binance "github.com/CapsLock-Studio/binance-premium-index/models"
+)
+
+const (
+	BINANCE_FAPI_ENDPOINT      string = "https://fapi.binance.com"
+	BINANCE_FAPI_LEVERAGE      string = "/fapi/v1/leverage"
+	BINANCE_FAPI_BATCH_ORDERS  string = "/fapi/v1/batchOrders"
+	BINANCE_FAPI_DEPTH         string = "/fapi/v1/depth"
+	BINANCE_FAPI_OPEN_ORDERS   string = "/fapi/v1/positionRisk"
+	BINANCE_FAPI_BALANCE       string = "/fapi/v2/balance"
+	BINANCE_FAPI_ACCOUNT       string = "/fapi/v2/account"
+	BINANCE_FAPI_MARGIN        string = "/fapi/v1/positionMargin"
+	BINANCE_FAPI_PREMIUM_INDEX string = "/fapi/v1/premiumIndex"
+	BINANCE_FAPI_INFO          string = "/fapi/v1/exchangeInfo"
+	BINANCE_FAPI_USER_TRADES   string = "/fapi/v1/userTrades"
+	BINANCE_FAPI_INCOME        string = "/fapi/v1/income"
+)
+
+// Binance talks to the USDT-M futures API.
+type Binance struct {
+	Endpoint   string
+	ApiKey     string
+	ApiSecret  string
+	Budget     *Budget
+	Limiter    *Limiter
+	Clock      *Clock
+	Retries    int
+	RecvWindow int
+	keys       sync.RWMutex
+}
+
+func NewBinance(apiKey, apiSecret string) *Binance {
+	b := &Binance{
+		Endpoint:  BINANCE_FAPI_ENDPOINT,
+		ApiKey:    apiKey,
+		ApiSecret: apiSecret,
+		Retries:   BINANCE_MAX_RETRIES,
+	}
+
+	b.Clock = NewClock(b.GetServerTime)
+
+	return b
+}
+
+func (b *Binance) GetKeys() (apiKey, apiSecret string) {
+	b.keys.RLock()
+	defer b.keys.RUnlock()
+
+	return b.ApiKey, b.ApiSecret
+}
+
+// SetKeys swaps the key pair of a running client, the next request is signed with it.
+func (b *Binance) SetKeys(apiKey, apiSecret string) {
+	b.keys.Lock()
+	defer b.keys.Unlock()
+
+	b.ApiKey = apiKey
+	b.ApiSecret = apiSecret
+}
+
+func (b *Binance) MakeRequest(
+	path,
+	method string,
+	body map[string]string,
+) *gorequest.SuperAgent {
+	apiKey, apiSecret := b.GetKeys()
+
+	if body != nil {
+		params := url.Values{}
+
+		for key, value := range body {
+			params.Add(key, value)
+		}
+
+		// market data clients have no key and only call public endpoints
+		if apiSecret != "" {
+			// recvWindow=0 leaves Binance default of 5000ms
+			if b.RecvWindow > 0 {
+				params.Add("recvWindow", fmt.Sprint(b.RecvWindow))
+			}
+
+			params.Add("timestamp", decimal.NewFromInt(b.Clock.Now()).String())
+
+			mac := hmac.New(sha256.New, []byte(apiSecret))
+			mac.Write([]byte(params.Encode()))
+			signingKey := fmt.Sprintf("%x", mac.Sum(nil))
+
+			params.Add("signature", signingKey)
+		}
+
+		path += "?" + params.Encode()
+	}
+
+	req := gorequest.
+		New().
+		CustomMethod(method, b.Endpoint+path)
+
+	req.Header.Set("X-MBX-APIKEY", apiKey)
+
+	return req
+}
+
+// Request sends a request within the weight budget of the host
+// and decodes the response into v unless v is nil.
+// Idempotent requests are retried with backoff while the error is retryable.
+func (b *Binance) Request(path, method string, body map[string]string, v any) ([]byte, error) {
+	retries := 0
+
+	if isIdempotent(path, method) {
+		retries = b.Retries
+	}
+
+	for attempt := 0; ; attempt++ {
+		data, err := b.request(path, method, body, v)
+
+		if err == nil || attempt >= retries || !IsRetryable(err) {
+			return data, err
+		}
+
+		time.Sleep(BINANCE_RETRY_BACKOFF << attempt)
+	}
+}
+
+func (b *Binance) request(path, method string, body map[string]string, v any) ([]byte, error) {
+	// looked up per request, keys can be rotated
+	apiKey, _ := b.GetKeys()
+	orders := b.Limiter.Orders(b.Endpoint, apiKey)
+
+	b.Budget.Wait(path)
+	orders.Wait(path)
+
+	resp, data, errs := b.MakeRequest(path, method, body).EndBytes()
+
+	if err := firstError(errs); err != nil {
+		return data, err
+	}
+
+	b.Budget.Update(resp.Header, resp.StatusCode)
+	orders.Update(resp.Header, resp.StatusCode)
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		binanceErr := &models.BinanceError{Status: resp.StatusCode}
+
+		if json.Unmarshal(data, binanceErr) != nil || binanceErr.Msg == "" {
+			binanceErr.Msg = http.StatusText(resp.StatusCode)
+		}
+
+		// drifted clock, the retry is signed with a fresh offset
+		if binanceErr.Code == BINANCE_INVALID_TIMESTAMP {
+			b.Clock.Invalidate()
+		}
+
+		return data, binanceErr
+	}
+
+	if v != nil {
+		if err := json.Unmarshal(data, v); err != nil {
+			return data, err
+		}
+	}
+
+	return data, nil
+}
+
+func (b *Binance) GetDepth(
+	symbol string,
+) (
+	bid,
+	bidSize,
+	ask,
+	askSize float64,
+	err error,
+) {
+	return b.getDepth(BINANCE_FAPI_DEPTH, symbol)
+}
+
+func (b *Binance) getDepth(
+	path,
+	symbol string,
+) (
+	bid,
+	bidSize,
+	ask,
+	askSize float64,
+	err error,
+) {
+	depth := struct {
+		Asks [][]string `json:"asks"`
+		Bids [][]string `json:"bids"`
+	}{}
+
+	_, err = b.Request(
+		path,
+		gorequest.GET,
+		map[string]string{
+			"limit":  "5",
+			"symbol": symbol,
+		},
+		&depth,
+	)
+
+	if err != nil {
+		return
+	}
+
+	if len(depth.Bids) > 0 && len(depth.Asks) > 0 {
+		bid, _ = strconv.ParseFloat(depth.Bids[len(depth.Bids)-1][0], 64)
+		ask, _ = strconv.ParseFloat(depth.Asks[len(depth.Asks)-1][0], 64)
+		bidSize, _ = strconv.ParseFloat(depth.Bids[len(depth.Bids)-1][1], 64)
+		askSize, _ = strconv.ParseFloat(depth.Asks[len(depth.Asks)-1][1], 64)
+	}
+
+	return
+}
+
+func (b *Binance) GetPositionRisk() ([]models.BinanceOrder, error) {
+	positions := make([]models.BinanceOrder, 0)
+
+	_, err := b.Request(
+		BINANCE_FAPI_OPEN_ORDERS,
+		gorequest.GET,
+		map[string]string{},
+		&positions,
+	)
+
+	return positions, err
+}
+
+func (b *Binance) SetLeverage(symbol string, leverage int) error {
+	_, err := b.Request(
+		BINANCE_FAPI_LEVERAGE,
+		gorequest.POST,
+		map[string]string{
+			"symbol":   symbol,
+			"leverage": fmt.Sprint(leverage),
+		},
+		nil,
+	)
+
+	return err
+}
+
+// PlaceBatchOrders returns one result per order, rejected orders carry code & msg.
+// Results come with executedQty and avgPrice, an ACK would only say the order is new.
+func (b *Binance) PlaceBatchOrders(orders []models.BinancePlaceOrder) ([]models.BinanceOrderResult, error) {
+	placed := make([]models.BinancePlaceOrder, len(orders))
+
+	for i, v := range orders {
+		v.NewOrderRespType = "RESULT"
+		placed[i] = v
+	}
+
+	batchOrders, err := json.Marshal(placed)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := b.Request(
+		BINANCE_FAPI_BATCH_ORDERS,
+		gorequest.POST,
+		map[string]string{
+			"batchOrders": string(batchOrders),
+		},
+		nil,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.BinanceOrderResult, 0)
+
+	if err := json.Unmarshal(body, &results); err != nil {
+		// the whole batch was rejected
+		var result models.BinanceOrderResult
+
+		if json.Unmarshal(body, &result) == nil && result.Code != 0 {
+			return nil, &models.BinanceError{Code: result.Code, Msg: result.Msg}
+		}
+
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (b *Binance) GetBalance() ([]models.BinanceBalance, error) {
+	balances := make([]models.BinanceBalance, 0)
+
+	_, err := b.Request(
+		BINANCE_FAPI_BALANCE,
+		gorequest.GET,
+		map[string]string{},
+		&balances,
+	)
+
+	return balances, err
+}
+
+func (b *Binance) GetAccount() (models.BinanceAccount, error) {
+	var account models.BinanceAccount
+
+	_, err := b.Request(
+		BINANCE_FAPI_ACCOUNT,
+		gorequest.GET,
+		map[string]string{},
+		&account,
+	)
+
+	return account, err
+}
+
+// ModifyMargin adds or removes isolated margin of symbol.
+func (b *Binance) ModifyMargin(symbol string, amount float64, add bool) error {
+	marginType := "2"
+
+	if add {
+		marginType = "1"
+	}
+
+	_, err := b.Request(
+		BINANCE_FAPI_MARGIN,
+		gorequest.POST,
+		map[string]string{
+			"symbol": symbol,
+			"amount": decimal.NewFromFloat(amount).String(),
+			"type":   marginType,
+		},
+		nil,
+	)
+
+	return err
+}
+
+func (b *Binance) GetExchangeInfo() ([]models.BinanceSymbolInfo, error) {
+	return b.getExchangeInfo(BINANCE_FAPI_INFO)
+}
+
+func (b *Binance) getExchangeInfo(path string) ([]models.BinanceSymbolInfo, error) {
+	info := struct {
+		Symbols []models.BinanceSymbolInfo `json:"symbols"`
+	}{}
+
+	_, err := b.Request(path, gorequest.GET, nil, &info)
+
+	return info.Symbols, err
+}
+
+func (b *Binance) GetOrderTrades(symbol string, orderID int64) ([]models.BinanceTrade, error) {
+	trades := make([]models.BinanceTrade, 0)
+
+	_, err := b.Request(
+		BINANCE_FAPI_USER_TRADES,
+		gorequest.GET,
+		map[string]string{
+			"symbol":  symbol,
+			"orderId": fmt.Sprint(orderID),
+		},
+		&trades,
+	)
+
+	return trades, err
+}
+
+func (b *Binance) GetIncome(symbol, incomeType string, startTime int64) ([]models.BinanceIncome, error) {
+	incomes := make([]models.BinanceIncome, 0)
+
+	_, err := b.Request(
+		BINANCE_FAPI_INCOME,
+		gorequest.GET,
+		map[string]string{
+			"symbol":     symbol,
+			"incomeType": incomeType,
+			"startTime":  fmt.Sprint(startTime),
+			"limit":      "1000",
+		},
+		&incomes,
+	)
+
+	return incomes, err
+}
+
+// GetPremiumIndex returns mark price and funding rate of every perpetual.
+func (b *Binance) GetPremiumIndex() ([]binance.BinancePremium, error) {
+	premiums := make([]binance.BinancePremium, 0)
+
+	_, err := b.Request(BINANCE_FAPI_PREMIUM_INDEX, gorequest.GET, nil, &premiums)
+
+	return premiums, err
+}
@@ -0,0 +1,144 @@
+package modules
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestBinance signs with local time, test servers don't serve server time.
+func newTestBinance(endpoint string) *Binance {
+	b := NewBinance("key", "secret")
+	b.Endpoint = endpoint
+	b.Clock = nil
+
+	return b
+}
+
+func TestBinanceDepth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, BINANCE_FAPI_DEPTH, r.URL.Path)
+		assert.Equal(t, "LDOUSDT", r.URL.Query().Get("symbol"))
+		assert.NotEmpty(t, r.URL.Query().Get("signature"))
+		assert.Equal(t, "key", r.Header.Get("X-MBX-APIKEY"))
+
+		w.Write([]byte(`{"bids":[["1.1","10"],["1.0","20"]],"asks":[["1.2","30"],["1.3","40"]]}`))
+	}))
+	defer server.Close()
+
+	b := newTestBinance(server.URL)
+
+	bid, bidSize, ask, askSize, err := b.GetDepth("LDOUSDT")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, bid)
+	assert.Equal(t, 20.0, bidSize)
+	assert.Equal(t, 1.3, ask)
+	assert.Equal(t, 40.0, askSize)
+}
+
+func TestBinanceBatchOrders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, BINANCE_FAPI_BATCH_ORDERS, r.URL.Path)
+		assert.Contains(t, r.URL.Query().Get("batchOrders"), `"symbol":"LDOBUSD"`)
+		assert.Contains(t, r.URL.Query().Get("batchOrders"), `"newOrderRespType":"RESULT"`)
+
+		w.Write([]byte(`[{"orderId":1,"symbol":"LDOBUSD","side":"BUY","status":"NEW"},{"code":-2019,"msg":"Margin is insufficient."}]`))
+	}))
+	defer server.Close()
+
+	b := newTestBinance(server.URL)
+
+	results, err := b.PlaceBatchOrders([]models.BinancePlaceOrder{
+		{Type: "MARKET", Symbol: "LDOBUSD", Side: "BUY", Quantity: "1"},
+		{Type: "MARKET", Symbol: "LDOUSDT", Side: "SELL", Quantity: "1"},
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.False(t, results[0].Rejected())
+	assert.True(t, results[1].Rejected())
+	assert.Equal(t, -2019, results[1].Code)
+}
+
+func TestBinancePremiumIndex(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, BINANCE_FAPI_PREMIUM_INDEX, r.URL.Path)
+		assert.Empty(t, r.URL.Query().Get("signature"))
+
+		w.Write([]byte(`[{"symbol":"LDOUSDT","markPrice":"2.1","lastFundingRate":"0.0001","nextFundingTime":1700000000000,"time":1699990000000}]`))
+	}))
+	defer server.Close()
+
+	b := newTestBinance(server.URL)
+
+	premiums, err := b.GetPremiumIndex()
+
+	assert.NoError(t, err)
+	assert.Len(t, premiums, 1)
+	assert.Equal(t, "2.1", premiums[0].MarkPrice)
+	assert.Equal(t, 1700000000000, premiums[0].NextFundingTime)
+}
+
+func TestBinanceRetry(t *testing.T) {
+	calls := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls += 1
+
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	b := newTestBinance(server.URL)
+
+	positions, err := b.GetPositionRisk()
+
+	assert.NoError(t, err)
+	assert.Empty(t, positions)
+	assert.Equal(t, 2, calls)
+}
+
+func TestBinanceErrors(t *testing.T) {
+	calls := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls += 1
+
+		if r.URL.Path == BINANCE_FAPI_BATCH_ORDERS {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"code":-1000,"msg":"Unknown error, please check your request or try again later."}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"code":-2015,"msg":"Invalid API-key, IP, or permissions for action."}`))
+	}))
+	defer server.Close()
+
+	b := newTestBinance(server.URL)
+
+	// fatal errors aren't retried
+	_, err := b.GetPositionRisk()
+
+	assert.EqualError(t, err, "binance error -2015: Invalid API-key, IP, or permissions for action.")
+	assert.True(t, IsFatal(err))
+	assert.False(t, IsRetryable(err))
+	assert.Equal(t, 1, calls)
+
+	// orders are never sent twice
+	_, err = b.PlaceBatchOrders([]models.BinancePlaceOrder{{Type: "MARKET", Symbol: "LDOUSDT", Side: "BUY", Quantity: "1"}})
+
+	assert.True(t, IsRetryable(err))
+	assert.False(t, IsFatal(err))
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,74 @@
+package modules
+
+import (
+	"sync"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+)
+
+const (
+	BROKER_BUFFER int = 100
+)
+
+// Broker fans bot events out to live subscribers,
+// a slow subscriber misses events instead of blocking the bots.
+type Broker struct {
+	subscriptions map[*EventSubscription]bool
+	mutex         sync.RWMutex
+}
+
+// EventSubscription receives events of one user, of a single bot when BotID is set.
+type EventSubscription struct {
+	UserID string
+	BotID  string
+	Events chan models.BotEvent
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subscriptions: make(map[*EventSubscription]bool),
+	}
+}
+
+func (b *Broker) Subscribe(userID, botID string) *EventSubscription {
+	s := &EventSubscription{
+		UserID: userID,
+		BotID:  botID,
+		Events: make(chan models.BotEvent, BROKER_BUFFER),
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.subscriptions[s] = true
+
+	return s
+}
+
+func (b *Broker) Unsubscribe(s *EventSubscription) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	delete(b.subscriptions, s)
+}
+
+// Publish delivers event to every matching subscription, a nil Broker drops it.
+func (b *Broker) Publish(event models.BotEvent) {
+	if b == nil {
+		return
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	for s := range b.subscriptions {
+		if s.UserID != event.UserID || (s.BotID != "" && s.BotID != event.ID) {
+			continue
+		}
+
+		select {
+		case s.Events <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package modules
+
+import (
+	"testing"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker(t *testing.T) {
+	broker := NewBroker()
+
+	all := broker.Subscribe("user", "")
+	bot := broker.Subscribe("user", "bot")
+	other := broker.Subscribe("other", "")
+
+	broker.Publish(models.BotEvent{Type: "create", ID: "bot", UserID: "user"})
+	broker.Publish(models.BotEvent{Type: "place", ID: "another", UserID: "user"})
+
+	assert.Len(t, all.Events, 2)
+	assert.Len(t, bot.Events, 1)
+	assert.Empty(t, other.Events)
+	assert.Equal(t, "create", (<-bot.Events).Type)
+
+	// a full subscription drops events instead of blocking
+	for i := 0; i < BROKER_BUFFER+1; i++ {
+		broker.Publish(models.BotEvent{Type: "place", ID: "bot", UserID: "user"})
+	}
+
+	assert.Len(t, bot.Events, BROKER_BUFFER)
+
+	broker.Unsubscribe(bot)
+	broker.Publish(models.BotEvent{Type: "reverse", ID: "bot", UserID: "user"})
+
+	assert.Len(t, bot.Events, BROKER_BUFFER)
+
+	// bots without a broker don't publish
+	var none *Broker
+	none.Publish(models.BotEvent{Type: "create"})
+}
@@ -0,0 +1,97 @@
+package modules
+
+import (
+	"sync"
+	"time"
+
+	"github.com/parnurzeal/gorequest"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	BINANCE_FAPI_TIME string = "/fapi/v1/time"
+	BINANCE_SPOT_TIME string = "/api/v3/time"
+
+	CLOCK_INTERVAL time.Duration = 10 * time.Minute
+)
+
+// Clock keeps the offset between local and Binance server time,
+// so signed timestamps stay inside recvWindow on drifting hosts.
+type Clock struct {
+	Fetch    func() (int64, error)
+	Interval time.Duration
+	offset   int64
+	synced   time.Time
+	mutex    sync.Mutex
+}
+
+func NewClock(fetch func() (int64, error)) *Clock {
+	return &Clock{
+		Fetch:    fetch,
+		Interval: CLOCK_INTERVAL,
+	}
+}
+
+// Now returns server time in milliseconds, synced again once Interval passed.
+// A nil Clock returns local time.
+func (c *Clock) Now() int64 {
+	if c == nil {
+		return time.Now().UnixMilli()
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if time.Since(c.synced) >= c.Interval {
+		c.sync()
+	}
+
+	return time.Now().UnixMilli() + c.offset
+}
+
+// Invalidate syncs again on the next Now, e.g. after a -1021 rejection.
+func (c *Clock) Invalidate() {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.synced = time.Time{}
+}
+
+func (c *Clock) sync() {
+	sent := time.Now().UnixMilli()
+
+	serverTime, err := c.Fetch()
+
+	// keep the last offset until the next interval
+	c.synced = time.Now()
+
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	received := time.Now().UnixMilli()
+
+	// assume the server answered halfway through the round trip
+	c.offset = serverTime - (sent+received)/2
+
+	logrus.WithField("offset", c.offset).Debug("server time synced")
+}
+
+func (b *Binance) GetServerTime() (int64, error) {
+	return b.getServerTime(BINANCE_FAPI_TIME)
+}
+
+func (b *Binance) getServerTime(path string) (int64, error) {
+	result := struct {
+		ServerTime int64 `json:"serverTime"`
+	}{}
+
+	_, err := b.Request(path, gorequest.GET, nil, &result)
+
+	return result.ServerTime, err
+}
@@ -0,0 +1,54 @@
+package modules
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClock(t *testing.T) {
+	// server clock is a minute ahead
+	drift := int64(time.Minute / time.Millisecond)
+	synced := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == BINANCE_FAPI_TIME {
+			synced += 1
+
+			fmt.Fprintf(w, `{"serverTime":%d}`, time.Now().UnixMilli()+drift)
+			return
+		}
+
+		timestamp, _ := strconv.ParseInt(r.URL.Query().Get("timestamp"), 10, 64)
+
+		assert.InDelta(t, time.Now().UnixMilli()+drift, timestamp, 1000)
+		assert.Equal(t, "10000", r.URL.Query().Get("recvWindow"))
+
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	b := NewBinance("key", "secret")
+	b.Endpoint = server.URL
+	b.RecvWindow = 10000
+
+	_, err := b.GetPositionRisk()
+	assert.NoError(t, err)
+
+	_, err = b.GetPositionRisk()
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, synced)
+
+	b.Clock.Invalidate()
+
+	_, err = b.GetPositionRisk()
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, synced)
+}
@@ -1,14 +1,12 @@
 package modules
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"math"
-	"net/url"
-	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/CapsLock-Studio/binance-premium-bot/models"
@@ -22,40 +20,171 @@ import (
 )
 
 const (
-	BINANCE_FAPI_ENDPOINT     string = "https://fapi.binance.com/fapi/v1"
-	BINANCE_FAPI_LEVERAGE     string = "/leverage"
-	BINANCE_FAPI_BATCH_ORDERS string = "/batchOrders"
-	BINANCE_FAPI_DEPTH        string = "/depth"
-	BINANCE_FAPI_OPEN_ORDERS  string = "/positionRisk"
-
 	FUNDING_RATE_ENDPOINT string = "https://wiwisorich.capslock.tw"
 
-	DEFAULT_LEVERAGE   int     = 10
-	DEFAULT_DIFFERENCE float64 = 0.05
-	DEFAULT_MINUTES    float64 = 480
+	DEFAULT_LEVERAGE        int     = 10
+	DEFAULT_DIFFERENCE      float64 = 0.05
+	DEFAULT_MINUTES         float64 = 480
+	DEFAULT_PRIMARY_QUOTE   string  = "USDT"
+	DEFAULT_SECONDARY_QUOTE string  = "USDC"
+	DEFAULT_RECV_WINDOW     int     = 5000
+	MAX_RECV_WINDOW         int     = 60000
 )
 
 type Core struct {
 	Setting        *models.ConfigSetting
-	EventReceiver  chan string
+	Exchange       Exchange
+	Provider       HedgeProvider
+	Subscription   *Subscription
+	Stream         *Stream
+	UserStream     *UserSubscription
+	Spot           SpotExchange
+	Info           *ExchangeInfo
+	SpotInfo       *ExchangeInfo
+	State          *models.CoreState
+	Store          StateStore
+	Trades         TradeStore
+	Incomes        IncomeStore
 	ID             *string
 	RateLimiter    ratelimit.Limiter
 	EventPublisher chan models.EventMessage
+	Broker         *Broker
+	clients        []*Binance
+	lastCheckpoint []byte
+	lastIncome     time.Time
+	liquidated     float64
+	fatal          error
+	unwinding      bool
+	afterUnwind    *models.ConfigSetting
+	done           context.Context
+	cancel         context.CancelFunc
+	paused         atomic.Bool
+	updates        []models.SettingPatch
+	updateMutex    sync.Mutex
+	status         models.BotStatus
+	running        models.ConfigSetting
+	statusMutex    sync.Mutex
+
+	lastMarkPriceGap   float64
+	lastFundingRateGap float64
+	lastOrder          time.Time
+	lastError          error
+	lastErrorTime      time.Time
+
+	quantityPerOrder float64
+	progressBarTotal int
 }
 
 func NewCore(
 	setting *models.ConfigSetting,
-	eventReceiver chan string,
+	exchange Exchange,
 	ID *string,
 	ratelimiter ratelimit.Limiter,
 ) *Core {
-	return &Core{
+	done, cancel := context.WithCancel(context.Background())
+
+	core := &Core{
 		Setting:        setting,
-		EventReceiver:  eventReceiver,
+		Exchange:       exchange,
+		Info:           NewExchangeInfo(exchange.GetExchangeInfo),
 		ID:             ID,
 		RateLimiter:    ratelimiter,
 		EventPublisher: make(chan models.EventMessage),
+		done:           done,
+		cancel:         cancel,
+	}
+
+	// a snapshot before the loop runs
+	core.UpdateStatus()
+
+	return core
+}
+
+// NewBinanceCore wires a Core with the Binance clients its setting needs.
+// Market data comes from websocket streams when setting.Stream is on,
+// otherwise from the hub instead of polling per bot.
+func NewBinanceCore(
+	setting *models.ConfigSetting,
+	ID *string,
+	limiter *Limiter,
+	hub *Hub,
+) *Core {
+	b := limiter.Attach(NewBinance(setting.ApiKey, setting.ApiSecret))
+	b.RecvWindow = setting.RecvWindow
+
+	core := NewCore(setting, b, ID, limiter.Bot())
+	core.clients = append(core.clients, b)
+	core.Provider = NewHedgeProvider(b.GetPremiumIndex, setting)
+
+	symbols := core.GetSymbols()
+
+	if setting.Stream {
+		exchange := &StreamExchange{
+			Binance: b,
+			Stream:  NewStream(symbols...),
+		}
+
+		core.Exchange = exchange
+		core.Provider = NewHedgeProvider(exchange.GetPremiumIndex, setting)
+		core.Stream = exchange.Stream
+	} else if hub != nil {
+		exchange := &HubExchange{
+			Binance:      b,
+			Subscription: hub.Subscribe(symbols...),
+		}
+
+		core.Exchange = exchange
+		core.Provider = NewHedgeProvider(exchange.GetPremiumIndex, setting)
+		core.Subscription = exchange.Subscription
 	}
+
+	if setting.Spot {
+		spot := NewBinanceSpot(setting.ApiKey, setting.ApiSecret)
+		spot.RecvWindow = setting.RecvWindow
+		limiter.Attach(spot.Binance)
+
+		core.Spot = spot
+		core.clients = append(core.clients, spot.Binance)
+		core.SpotInfo = NewExchangeInfo(core.Spot.GetExchangeInfo)
+	}
+
+	if setting.Paused {
+		core.Pause()
+	}
+
+	if setting.UserStream && !setting.DryRun {
+		core.UserStream = NewUserSubscription(b)
+	}
+
+	// exchange info and market data stay live, orders go to the simulator
+	if setting.DryRun {
+		simulator := NewSimulator(SIMULATED_FEE)
+		simulator.Market = core.Exchange
+		simulator.Upstream = core.Provider
+
+		core.Exchange = simulator
+		core.Provider = simulator
+	}
+
+	return core
+}
+
+// GetSymbols returns the perpetuals the bot trades.
+func (c *Core) GetSymbols() []string {
+	symbols := []string{c.Setting.Symbol + c.Setting.PrimaryQuote}
+
+	if !c.Setting.Spot {
+		symbols = append(symbols, c.Setting.Symbol+c.Setting.SecondaryQuote)
+	}
+
+	return symbols
+}
+
+// Publish sends an event with the symbol and webhook of the current setting.
+func (c *Core) Publish(event models.EventMessage) {
+	event.Symbol = c.Setting.Symbol
+	event.Webhook = c.Setting.Webhook
+	c.EventPublisher <- event
 }
 
 func (c *Core) GetPublisher() <-chan models.EventMessage {
@@ -70,82 +199,52 @@ func (c *Core) GetDepth(
 	ask,
 	askSize float64,
 ) {
-	depth := struct {
-		Asks [][]string `json:"asks"`
-		Bids [][]string `json:"bids"`
-	}{}
-
-	c.MakeRequest(
-		BINANCE_FAPI_DEPTH,
-		gorequest.GET,
-		map[string]string{
-			"limit":  "5",
-			"symbol": c.Setting.Symbol + currency,
-		},
-	).EndStruct(&depth)
-
-	if len(depth.Bids) > 0 && len(depth.Asks) > 0 {
-		bid, _ = strconv.ParseFloat(depth.Bids[len(depth.Bids)-1][0], 64)
-		ask, _ = strconv.ParseFloat(depth.Asks[len(depth.Asks)-1][0], 64)
-		bidSize, _ = strconv.ParseFloat(depth.Bids[len(depth.Bids)-1][1], 64)
-		askSize, _ = strconv.ParseFloat(depth.Asks[len(depth.Asks)-1][1], 64)
+	bid, bidSize, ask, askSize, err := c.Exchange.GetDepth(c.Setting.Symbol + currency)
+	if err != nil {
+		logrus.WithField("symbol", c.Setting.Symbol+currency).Error(err)
 	}
 
 	return
 }
 
-func (c *Core) MakeRequest(
-	path,
-	method string,
-	body map[string]string,
-) *gorequest.SuperAgent {
-	if body != nil {
-		params := url.Values{}
-
-		for key, value := range body {
-			params.Add(key, value)
-		}
-
-		params.Add("timestamp", decimal.NewFromInt(time.Now().UnixMilli()).String())
-
-		mac := hmac.New(sha256.New, []byte(c.Setting.ApiSecret))
-		mac.Write([]byte(params.Encode()))
-		signingKey := fmt.Sprintf("%x", mac.Sum(nil))
-
-		params.Add("signature", signingKey)
-
-		path += "?" + params.Encode()
+// Run trades until the bot is done, closed or stopped by ctx,
+// a stop lets the current batch finish and follows the shutdown policy of the setting.
+func (c *Core) Run(ctx context.Context) {
+	if c.Subscription != nil {
+		defer c.Subscription.Close()
 	}
 
-	req := gorequest.
-		New().
-		CustomMethod(method, BINANCE_FAPI_ENDPOINT+path)
-
-	req.Header.Set("X-MBX-APIKEY", c.Setting.ApiKey)
+	if c.Stream != nil {
+		go c.Stream.Run()
+		defer c.Stream.Close()
+	}
 
-	return req
-}
+	if c.UserStream != nil {
+		c.UserStream.Open()
+		defer c.UserStream.Close()
+	}
 
-func (c *Core) Run() {
 	go func() {
 		for v := range c.GetPublisher() {
-			if v.Setting.Webhook != "" {
-				var ID string
+			event := models.BotEvent{
+				Type:    v.Type,
+				Symbol:  v.Symbol,
+				Message: v.Message,
+				UserID:  c.Setting.UserID,
+				Time:    time.Now().UnixMilli(),
+			}
 
-				if c.ID != nil {
-					ID = *c.ID
-				}
+			if c.ID != nil {
+				event.ID = *c.ID
+			}
 
+			c.Broker.Publish(event)
+
+			if v.Webhook != "" {
 				gorequest.
 					New().
-					Post(v.Setting.Webhook).
-					Send(map[string]any{
-						"type":    v.Type,
-						"id":      ID,
-						"symbol":  v.Setting.Symbol,
-						"message": v.Message,
-						"userId":  c.Setting.UserID,
-					})
+					Post(v.Webhook).
+					Send(event)
 			}
 		}
 	}()
@@ -153,6 +252,7 @@ func (c *Core) Run() {
 	logger := logrus.
 		New().
 		WithField("symbol", c.Setting.Symbol).
+		WithField("pair", c.Setting.PrimaryQuote+"/"+c.Setting.SecondaryQuote).
 		WithField("leverage", c.Setting.Leverage)
 
 	// add key information
@@ -164,61 +264,142 @@ func (c *Core) Run() {
 		logger = logger.WithField("id", *c.ID)
 	}
 
-	c.EventPublisher <- models.EventMessage{Type: "create", Setting: c.Setting}
+	if c.Setting.DryRun {
+		logger = logger.WithField("dryRun", true)
+	}
 
-	currentProgressBarTotal := 0
-	totalQuantity := c.Setting.Total
-	quantityPerOrder := c.Setting.Quantity
-	progressBarTotal := int(totalQuantity / quantityPerOrder)
+	c.UpdateStatus()
 
-	if int(math.Mod(totalQuantity, quantityPerOrder)) > 0 {
-		progressBarTotal += 1
+	for {
+		err := c.Validate()
+
+		if err == nil {
+			break
+		}
+
+		logger.Error(err)
+
+		if IsInvalidSetting(err) {
+			c.Publish(models.EventMessage{Type: "invalid", Message: err.Error()})
+			return
+		}
+
+		if c.ReceiveClose(logger) || ctx.Err() != nil {
+			return
+		}
+
+		time.Sleep(5 * time.Second)
 	}
 
-	maxProgressBar := progressBarTotal
+	c.Publish(models.EventMessage{Type: "create"})
 
-	// initialize flag
-	var currentDirection *bool
-	var fundingRateReverseMode bool
-	var arbitrageDirection *bool
-	var arbitrageTriggered bool
+	if c.Setting.Spot {
+		c.RunSpot(ctx, logger)
+		return
+	}
 
-	openPositions := make([]models.BinanceOrder, 0)
+	c.Prepare(logger)
 
-	c.MakeRequest(
-		BINANCE_FAPI_OPEN_ORDERS,
-		gorequest.GET,
-		map[string]string{
-			"recvWindow": "5000",
-		},
-	).EndStruct(&openPositions)
+	for {
+		// wait 1 seconds
+		c.RateLimiter.Take()
 
-	openPositionForUSDT := filter(openPositions, func(v models.BinanceOrder) bool {
-		return v.Symbol == c.Setting.Symbol+"USDT"
-	})
-	openPositionForBUSD := filter(openPositions, func(v models.BinanceOrder) bool {
-		return v.Symbol == c.Setting.Symbol+"BUSD"
-	})
+		if c.ReceiveClose(logger) || c.ReceiveFatal(logger) || c.ReceiveShutdown(ctx, logger) {
+			break
+		}
 
-	if len(openPositionForBUSD) > 0 && len(openPositionForUSDT) > 0 {
-		openQtyForBUSD, _ := decimal.NewFromString(openPositionForBUSD[0].PositionAmt)
-		openQtyForUSDT, _ := decimal.NewFromString(openPositionForUSDT[0].PositionAmt)
+		c.ReceiveUpdates(logger)
 
-		openQty, _ := decimal.Min(openQtyForBUSD.Abs(), openQtyForUSDT.Abs()).Float64()
+		if c.Paused() {
+			c.ReceiveUserEvents(logger)
+			c.UpdateStatus()
+			c.Wait(ctx)
+			continue
+		}
 
-		if openQty > 0 {
-			direction := openQtyForBUSD.GreaterThan(decimal.NewFromInt(0))
+		if !c.Tick(logger) {
+			break
+		}
+
+		c.Checkpoint(logger)
+		c.UpdateStatus()
+		c.Wait(ctx)
+	}
+}
+
+// Wait sleeps until the next tick, streamed bots wake up on a new book
+// and every bot on a stop of ctx or Close.
+func (c *Core) Wait(ctx context.Context) {
+	var updated chan bool
+
+	if c.Stream != nil {
+		updated = c.Stream.Updated
+	}
+
+	select {
+	case <-updated:
+	case <-ctx.Done():
+	case <-c.done.Done():
+	case <-time.After(1 * time.Second):
+	}
+}
+
+// Prepare sets up the loop state, resumed from checkpoint or guessed from open positions.
+func (c *Core) Prepare(logger *logrus.Entry) {
+	c.quantityPerOrder = c.Setting.Quantity
+	c.progressBarTotal = c.getProgressBarTotal()
+
+	// the setting as given, before the loop changes it
+	setting := *c.Setting
 
-			currentDirection = &direction
+	// resume from checkpoint, otherwise guess from open positions
+	restored := c.Restore(logger)
+
+	// reduce mode of an unwind on shutdown isn't the setting, finish what's
+	// left of it and start over from the setting afterwards
+	if restored && c.State.ShutdownUnwind {
+		positions, err := c.Exchange.GetPositionRisk()
+		if err != nil {
+			c.LogError(logger, err)
+		}
+
+		if hedged, _ := c.GetOpenHedge(positions); hedged > 0 {
+			logger.Info("resume unwinding from last shutdown, ", hedged, " left")
+
+			c.afterUnwind = &setting
+			c.Setting.Reduce = true
+			c.State.TotalQuantity = hedged
+		} else {
+			logger.Info("unwound on last shutdown, start over from open positions")
+			restored = false
+		}
+	}
+
+	if !restored {
+		c.State = &models.CoreState{
+			Step:           1,
+			MaxProgressBar: c.progressBarTotal,
+			TotalQuantity:  c.Setting.Total,
+		}
+
+		openPositions, err := c.Exchange.GetPositionRisk()
+		if err != nil {
+			c.LogError(logger, err)
+		}
+
+		openQty, direction := c.GetOpenHedge(openPositions)
+
+		if openQty > 0 {
+			c.State.CurrentDirection = direction
 
 			if !c.Setting.Reduce {
-				currentProgressBarTotal = maxProgressBar - int(openQty/quantityPerOrder)
+				c.State.CurrentProgressBarTotal = c.State.MaxProgressBar - int(openQty/c.quantityPerOrder)
 
-				if currentProgressBarTotal < 0 {
-					currentProgressBarTotal = 0
+				if c.State.CurrentProgressBarTotal < 0 {
+					c.State.CurrentProgressBarTotal = 0
 				}
-				totalQuantity, _ = decimal.
-					NewFromFloat(totalQuantity).
+				c.State.TotalQuantity, _ = decimal.
+					NewFromFloat(c.State.TotalQuantity).
 					Sub(decimal.NewFromFloat(openQty)).
 					Float64()
 			}
@@ -230,9 +411,6 @@ func (c *Core) Run() {
 		c.Setting.Arbitrage = false
 	}
 
-	// initialize step
-	step := 1
-
 	if c.Setting.Arbitrage {
 		logger.Info("You're in arbitrage mode.")
 		logger.Info("I'll help you place some orders.")
@@ -241,334 +419,532 @@ func (c *Core) Run() {
 
 		c.Setting.Total = c.Setting.Quantity
 		c.Setting.Difference = .08
-		totalQuantity = c.Setting.Total
+
+		if !restored {
+			c.State.TotalQuantity = c.Setting.Total
+		}
 	} else {
 		logger.Info("I'm trying to place some orders...")
 		logger.Info("Please be patient and keep waiting...")
 	}
 
-	for {
-		// wait 1 seconds
-		c.RateLimiter.Take()
+	// settings changed by the loop itself
+	if restored {
+		c.Setting.Total = c.State.Total
+		c.Setting.Reduce = c.State.Reduce
+	}
+}
 
-		if c.EventReceiver != nil && len(c.EventReceiver) > 0 {
-			logrus.Info("Check channel...")
-			buffered := <-c.EventReceiver
+// getProgressBarTotal returns how many orders it takes to open the total.
+func (c *Core) getProgressBarTotal() int {
+	total := int(c.Setting.Total / c.Setting.Quantity)
 
-			if buffered == *c.ID {
-				logger.Info("Receive close signal...")
-				break
-			}
+	if int(math.Mod(c.Setting.Total, c.Setting.Quantity)) > 0 {
+		total += 1
+	}
+
+	return total
+}
+
+// GetOpenHedge returns the quantity open on both legs and its direction, nil when nothing is open.
+func (c *Core) GetOpenHedge(positions []models.BinanceOrder) (float64, *bool) {
+	openPositionForPrimary := filter(positions, func(v models.BinanceOrder) bool {
+		return v.Symbol == c.Setting.Symbol+c.Setting.PrimaryQuote
+	})
+	openPositionForSecondary := filter(positions, func(v models.BinanceOrder) bool {
+		return v.Symbol == c.Setting.Symbol+c.Setting.SecondaryQuote
+	})
+
+	if len(openPositionForSecondary) == 0 || len(openPositionForPrimary) == 0 {
+		return 0, nil
+	}
+
+	openQtyForSecondary, _ := decimal.NewFromString(openPositionForSecondary[0].PositionAmt)
+	openQtyForPrimary, _ := decimal.NewFromString(openPositionForPrimary[0].PositionAmt)
+
+	openQty, _ := decimal.Min(openQtyForSecondary.Abs(), openQtyForPrimary.Abs()).Float64()
+
+	if openQty <= 0 {
+		return 0, nil
+	}
+
+	direction := openQtyForSecondary.GreaterThan(decimal.NewFromInt(0))
+
+	return openQty, &direction
+}
+
+// Tick runs one iteration of the hedge loop, it reports false once the bot is done.
+// Exchange and hedge data only come through Exchange and Provider,
+// so live, dry-run and backtest share the same decisions.
+func (c *Core) Tick(logger *logrus.Entry) bool {
+	c.CollectIncome(logger)
+	c.ReceiveUserEvents(logger)
+
+	positions, err := c.Exchange.GetPositionRisk()
+	if err != nil {
+		c.LogError(logger, err)
+	}
+
+	liquidated := c.liquidated
+	c.liquidated = 0
+
+	var reduced float64
+
+	if liquidated > 0 {
+		// close the other leg down to what's left of the liquidated one
+		c.Repair(logger, positions, true)
+		reduced = liquidated
+	} else if reduced = c.Protect(logger, positions); reduced == 0 {
+		c.Repair(logger, positions, c.Setting.Reduce || c.State.FundingRateReverseMode)
+	}
+
+	if reduced > 0 {
+		if c.Setting.Reduce {
+			c.State.TotalQuantity, _ = decimal.
+				NewFromFloat(c.State.TotalQuantity).
+				Sub(decimal.NewFromFloat(reduced)).
+				Float64()
+		} else {
+			// don't reopen what protection or liquidation just closed
+			c.Setting.Total, _ = decimal.
+				NewFromFloat(c.Setting.Total).
+				Sub(decimal.NewFromFloat(reduced)).
+				Float64()
+		}
+	}
+
+	if c.State.TotalQuantity < 0 {
+		c.State.TotalQuantity = 0
+	}
+
+	if c.State.TotalQuantity <= 0 && c.Setting.Reduce && !c.Setting.Arbitrage {
+		// an unwind resumed after a restart is done, go on with the setting
+		if c.afterUnwind != nil && !c.unwinding {
+			logger.Info("unwound, start over")
+
+			c.Setting.Reduce = c.afterUnwind.Reduce
+			c.Setting.Arbitrage = c.afterUnwind.Arbitrage
+			c.Setting.Total = c.afterUnwind.Total
+			c.afterUnwind = nil
+			c.Prepare(logger)
 
-			logger.Info("Send to channel again")
-			c.EventReceiver <- buffered
+			return true
 		}
 
-		if totalQuantity < 0 {
-			totalQuantity = 0
+		return false
+	}
+
+	// enable arbitrage mode
+	if c.Setting.Arbitrage && c.State.TotalQuantity <= 0 {
+		c.Setting.Reduce = true
+		c.State.TotalQuantity = c.Setting.Total
+		c.State.ArbitrageTriggered = true
+	}
+
+	if c.State.TotalQuantity >= c.Setting.Total {
+		c.State.TotalQuantity = c.Setting.Total
+
+		// create new bar
+		if !c.State.FundingRateReverseMode {
+			c.State.CurrentProgressBarTotal = 0
+		}
+
+		if c.State.CurrentProgressBarTotal >= c.State.MaxProgressBar {
+			c.State.FundingRateReverseMode = false
+			c.State.Step = 1
+
+			c.State.MaxProgressBar = c.progressBarTotal
 		}
+	}
+
+	// update quantity per order
+	if c.quantityPerOrder > c.State.TotalQuantity {
+		c.quantityPerOrder = c.State.TotalQuantity
+	} else {
+		c.quantityPerOrder = c.Setting.Quantity
+	}
 
-		if totalQuantity <= 0 && c.Setting.Reduce && !c.Setting.Arbitrage {
+	// align quantity to lot size
+	c.quantityPerOrder = c.RoundQuantity(c.quantityPerOrder)
+
+	if c.quantityPerOrder <= 0 && c.State.TotalQuantity > 0 {
+		logger.Info("remaining quantity is less than lot size=", c.State.TotalQuantity)
+		c.State.TotalQuantity = 0
+	}
+
+	for _, h := range c.GetHedge() {
+		if h.Symbol == c.Setting.Symbol {
+			c.Trade(logger, h)
 			break
 		}
+	}
 
-		// enable arbitrage mode
-		if c.Setting.Arbitrage && totalQuantity <= 0 {
-			c.Setting.Reduce = true
-			totalQuantity = c.Setting.Total
-			arbitrageTriggered = true
+	return true
+}
+
+// Trade places at most one batch for hedge h when the setting allows it.
+func (c *Core) Trade(logger *logrus.Entry, h binance.BinanceHedge) {
+	primary := c.Setting.PrimaryQuote
+	secondary := c.Setting.SecondaryQuote
+
+	v := NewHedge(h.Symbol, primary, secondary, h.Index)
+
+	if v == nil {
+		logger.Warn("can't find premium index for ", primary, "/", secondary)
+		return
+	}
+
+	c.lastMarkPriceGap = v.MarkPriceGap
+	c.lastFundingRateGap = v.FundingRateGap
+
+	markPriceDirection := v.GetPrice(primary) > v.GetPrice(secondary)
+
+	logger.Info("MarkPriceGap=", v.MarkPriceGap)
+
+	if c.Setting.Arbitrage && c.Setting.Difference > v.MarkPriceGap {
+		return
+	}
+
+	if !c.Setting.Arbitrage {
+		if v.FundingRateGap == 0 && !c.Setting.Reduce {
+			return
 		}
 
-		if totalQuantity >= c.Setting.Total {
-			totalQuantity = c.Setting.Total
+		if v.MarkPriceGap > c.Setting.Difference {
+			return
+		}
+	}
+
+	if c.State.ArbitrageDirection != nil && ((!c.State.ArbitrageTriggered && *c.State.ArbitrageDirection != markPriceDirection) || (c.State.ArbitrageTriggered && *c.State.ArbitrageDirection == markPriceDirection)) {
+		return
+	}
+
+	if c.State.CurrentDirection != nil && v.Direction != *c.State.CurrentDirection {
+		c.State.FundingRateReverseMode = true
+	}
 
-			// create new bar
-			if !fundingRateReverseMode {
-				currentProgressBarTotal = 0
+	// record arbitrage direction
+	if c.Setting.Arbitrage {
+		if c.State.ArbitrageDirection == nil {
+			if markPriceDirection == v.Direction {
+				return
 			}
 
-			if currentProgressBarTotal >= maxProgressBar {
-				fundingRateReverseMode = false
-				step = 1
+			c.State.ArbitrageDirection = &markPriceDirection
+		}
+
+		v.Direction = *c.State.ArbitrageDirection
+	}
+
+	var primaryBid float64
+	var primaryAsk float64
+	var secondaryBid float64
+	var secondaryAsk float64
+	var primaryBidSize float64
+	var primaryAskSize float64
+	var secondaryBidSize float64
+	var secondaryAskSize float64
+
+	logger.Info("ask bid & ask depth...")
+
+	var primaryLeverageErr error
+	var secondaryLeverageErr error
+
+	wg := &sync.WaitGroup{}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		primaryLeverageErr = c.Exchange.SetLeverage(v.Symbol+primary, c.Setting.Leverage)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		secondaryLeverageErr = c.Exchange.SetLeverage(v.Symbol+secondary, c.Setting.Leverage)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		primaryBid, primaryBidSize, primaryAsk, primaryAskSize = c.GetDepth(primary)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		secondaryBid, secondaryBidSize, secondaryAsk, secondaryAskSize = c.GetDepth(secondary)
+	}()
+
+	// wait sync group
+	wg.Wait()
+
+	c.LogError(logger, primaryLeverageErr)
+	c.LogError(logger, secondaryLeverageErr)
+
+	rules := []bool{
+		primaryBidSize > c.quantityPerOrder,
+		secondaryBidSize > c.quantityPerOrder,
+		primaryAskSize > c.quantityPerOrder,
+		secondaryAskSize > c.quantityPerOrder,
+		c.quantityPerOrder > 0,
+	}
+
+	logger.
+		WithField(primary+" BID SIZE", primaryBidSize).
+		WithField(primary+" ASK SIZE", primaryAskSize).
+		WithField(secondary+" BID SIZE", secondaryBidSize).
+		WithField(secondary+" ASK SIZE", secondaryAskSize).
+		WithField("quantity", c.quantityPerOrder).
+		WithField("total", c.State.TotalQuantity).
+		Info("check size and order quantity")
+
+	if slices.Contains(rules, false) {
+		return
+	}
 
-				maxProgressBar = progressBarTotal
+	// update var
+	c.State.CurrentProgressBarTotal += 1
+
+	// handle order
+	if c.Setting.Reduce {
+		v.Direction = !v.Direction
+
+		if c.State.CurrentDirection == nil {
+			logger.Info("can't find current direction")
+			return
+		}
+
+		if *c.State.CurrentDirection {
+			v.Direction = false
+		} else {
+			v.Direction = true
+		}
+	} else if c.State.CurrentDirection == nil {
+		c.State.CurrentDirection = &v.Direction
+	} else if *c.State.CurrentDirection != v.Direction {
+		yield := (v.FundingRateGap * 365 * 3 * float64(c.Setting.Leverage)) / 2
+
+		if !c.Setting.Reduce {
+			if c.Setting.Threshold > yield {
+				logger.Info("not reduce mode and threshold is greater then yield=", yield)
+				return
+			}
+
+			minutes := v.GetLeftMinutes(secondary)
+			if minutes >= c.Setting.Before {
+				logger.
+					WithField("before", c.Setting.Before).
+					WithField("minutes", minutes).
+					Info("left minutes is greater than config")
+				return
 			}
 		}
 
-		// update quantity per order
-		if quantityPerOrder > totalQuantity {
-			quantityPerOrder = totalQuantity
+		c.Publish(models.EventMessage{Type: "reverse"})
+
+		if c.State.TotalQuantity >= c.Setting.Total {
+			c.State.Step = 1
 		} else {
-			quantityPerOrder = c.Setting.Quantity
+			c.State.Step = -1
 		}
 
-		hedge := make([]binance.BinanceHedge, 0)
+		c.State.CurrentDirection = &v.Direction
 
-		// fetch hedge information
-		gorequest.
-			New().
-			Get(FUNDING_RATE_ENDPOINT).
-			EndStruct(&hedge)
+		// reset quantity
+		c.quantityPerOrder = c.Setting.Quantity
 
-		for _, v := range hedge {
-			if v.Symbol == c.Setting.Symbol {
-				markPriceDirection := v.GetPrice("USDT") > v.GetPrice("BUSD")
+		logger.Info("direction changed, close orders...")
 
-				logger.Info("MarkPriceGap=", v.MarkPriceGap)
+		// change max
+		if c.State.CurrentProgressBarTotal > c.progressBarTotal {
+			c.State.MaxProgressBar = c.progressBarTotal
+		} else {
+			c.State.MaxProgressBar = c.State.CurrentProgressBarTotal
+		}
 
-				if c.Setting.Arbitrage && c.Setting.Difference > v.MarkPriceGap {
-					break
-				}
+		c.State.CurrentProgressBarTotal = 0
+	}
 
-				if !c.Setting.Arbitrage {
-					if v.FundingRateGap == 0 && !c.Setting.Reduce {
-						continue
-					}
+	c.quantityPerOrder = c.RoundQuantity(c.quantityPerOrder)
+	perQuantity := decimal.NewFromFloat(c.quantityPerOrder).String()
 
-					if v.MarkPriceGap > c.Setting.Difference {
-						break
-					}
-				}
+	binanceOrderSecondary := models.BinancePlaceOrder{
+		Type:     "MARKET",
+		Symbol:   v.Symbol + secondary,
+		Quantity: perQuantity,
+	}
+	binanceOrderPrimary := models.BinancePlaceOrder{
+		Type:     "MARKET",
+		Symbol:   v.Symbol + primary,
+		Quantity: perQuantity,
+	}
 
-				if arbitrageDirection != nil && ((!arbitrageTriggered && *arbitrageDirection != markPriceDirection) || (arbitrageTriggered && *arbitrageDirection == markPriceDirection)) {
-					break
-				}
+	if v.Direction {
+		binanceOrderSecondary.Side = "BUY"
+		binanceOrderPrimary.Side = "SELL"
+	} else {
+		binanceOrderSecondary.Side = "SELL"
+		binanceOrderPrimary.Side = "BUY"
+	}
 
-				if currentDirection != nil && v.Direction != *currentDirection {
-					fundingRateReverseMode = true
-				}
+	if c.Setting.Reduce || c.State.FundingRateReverseMode {
+		binanceOrderSecondary.ReduceOnly = "true"
+		binanceOrderPrimary.ReduceOnly = "true"
+	}
 
-				// record arbitrage direction
-				if c.Setting.Arbitrage {
-					if arbitrageDirection == nil {
-						if markPriceDirection == v.Direction {
-							break
-						}
+	orders := make([]models.BinancePlaceOrder, 0)
+	orders = append(orders, binanceOrderSecondary)
+	orders = append(orders, binanceOrderPrimary)
+
+	// place binance order
+	if c.State.TotalQuantity > 0 {
+		logger.Info(primary+" BID=", primaryBid)
+		logger.Info(primary+" ASK=", primaryAsk)
+		logger.Info(secondary+" BID=", secondaryBid)
+		logger.Info(secondary+" ASK=", secondaryAsk)
+
+		c.Publish(models.EventMessage{
+			Type: "place",
+			Message: map[string]float64{
+				primary + "_ASK_PRICE":   primaryAsk,
+				secondary + "_ASK_PRICE": secondaryAsk,
+				secondary + "_BID_PRICE": secondaryBid,
+				primary + "_BID_PRICE":   primaryBid,
+				primary + "_BID_SIZE":    primaryBidSize,
+				primary + "_ASK_SIZE":    primaryAskSize,
+				secondary + "_BID_SIZE":  secondaryBidSize,
+				secondary + "_ASK_SIZE":  secondaryAskSize,
+			},
+		})
+
+		batchOrders, _ := json.Marshal(orders)
+
+		logger.Info(string(batchOrders))
+
+		results, err := c.Exchange.PlaceBatchOrders(orders)
+		if err != nil {
+			c.LogError(logger, err)
+		}
 
-						arbitrageDirection = &markPriceDirection
-					}
+		c.Journal(logger, "place", results)
 
-					v.Direction = *arbitrageDirection
-				}
+		// a single accepted leg is completed by Repair on next tick
+		if c.CheckOrders(logger, results) > 0 {
+			// update total
+			value := decimal.
+				NewFromFloat(c.quantityPerOrder).
+				Mul(decimal.NewFromInt(int64(c.State.Step)))
 
-				var usdtBid float64
-				var usdtAsk float64
-				var busdBid float64
-				var busdAsk float64
-				var usdtBidSize float64
-				var usdtAskSize float64
-				var busdBidSize float64
-				var busdAskSize float64
+			// calculate totalQuantity
+			c.State.TotalQuantity, _ = decimal.
+				NewFromFloat(c.State.TotalQuantity).
+				Sub(value).
+				Float64()
+		}
+	}
+}
 
-				logger.Info("ask bid & ask depth...")
+// Close stops the bot at its next check, positions are kept.
+func (c *Core) Close() {
+	c.cancel()
+}
 
-				useLeverage := map[string]string{
-					"leverage": fmt.Sprint(c.Setting.Leverage),
-				}
+// Pause keeps the bot running without placing orders until Resume.
+func (c *Core) Pause() {
+	c.paused.Store(true)
+}
 
-				wg := &sync.WaitGroup{}
-
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-
-					useLeverage["symbol"] = v.Symbol + "USDT"
-					c.MakeRequest(BINANCE_FAPI_LEVERAGE, gorequest.POST, useLeverage).End()
-				}()
-
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-
-					useLeverage["symbol"] = v.Symbol + "BUSD"
-					c.MakeRequest(BINANCE_FAPI_LEVERAGE, gorequest.POST, useLeverage).End()
-				}()
-
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					usdtBid, usdtBidSize, usdtAsk, usdtAskSize = c.GetDepth("USDT")
-				}()
-
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					busdBid, busdBidSize, busdAsk, busdAskSize = c.GetDepth("BUSD")
-				}()
-
-				// wait sync group
-				wg.Wait()
-
-				rules := []bool{
-					usdtBidSize > quantityPerOrder,
-					busdBidSize > quantityPerOrder,
-					usdtAskSize > quantityPerOrder,
-					busdAskSize > quantityPerOrder,
-					quantityPerOrder > 0,
-				}
+func (c *Core) Resume() {
+	c.paused.Store(false)
+}
 
-				logger.
-					WithField("USDT BID SIZE", usdtBidSize).
-					WithField("USDT ASK SIZE", usdtAskSize).
-					WithField("BUSD BID SIZE", busdBidSize).
-					WithField("BUSD ASK SIZE", busdAskSize).
-					WithField("quantity", quantityPerOrder).
-					WithField("total", totalQuantity).
-					Info("check size and order quantity")
-
-				if slices.Contains(rules, false) {
-					break
-				}
+func (c *Core) Paused() bool {
+	return c.paused.Load()
+}
 
-				// update var
-				currentProgressBarTotal += 1
-
-				// handle order
-				if c.Setting.Reduce {
-					v.Direction = !v.Direction
-
-					if currentDirection == nil {
-						logger.Info("can't find current direction")
-						break
-					}
-
-					if *currentDirection {
-						v.Direction = false
-					} else {
-						v.Direction = true
-					}
-				} else if currentDirection == nil {
-					currentDirection = &v.Direction
-				} else if *currentDirection != v.Direction {
-					yield := (v.FundingRateGap * 365 * 3 * float64(c.Setting.Leverage)) / 2
-
-					if !c.Setting.Reduce {
-						if c.Setting.Threshold > yield {
-							logger.Info("not reduce mode and threshold is greater then yield=", yield)
-							continue
-						}
-
-						minutes := v.GetLeftMinutes("BUSD")
-						if minutes >= c.Setting.Before {
-							logger.
-								WithField("before", c.Setting.Before).
-								WithField("minutes", minutes).
-								Info("left minutes is greater than config")
-							continue
-						}
-					}
-
-					c.EventPublisher <- models.EventMessage{Type: "reverse", Setting: c.Setting}
-
-					if totalQuantity >= c.Setting.Total {
-						step = 1
-					} else {
-						step = -1
-					}
-
-					currentDirection = &v.Direction
-
-					// reset quantity
-					quantityPerOrder = c.Setting.Quantity
-
-					logger.Info("direction changed, close orders...")
-
-					// change max
-					if currentProgressBarTotal > progressBarTotal {
-						maxProgressBar = progressBarTotal
-					} else {
-						maxProgressBar = currentProgressBarTotal
-					}
-
-					currentProgressBarTotal = 0
-				}
+// ReceiveClose reports whether Close was called on this bot.
+func (c *Core) ReceiveClose(logger *logrus.Entry) bool {
+	if c.done.Err() == nil {
+		return false
+	}
 
-				perQuantity := decimal.NewFromFloat(quantityPerOrder).String()
+	logger.Info("Receive close signal...")
 
-				binanceOrderBUSD := models.BinancePlaceOrder{
-					Type:     "MARKET",
-					Symbol:   v.Symbol + "BUSD",
-					Quantity: perQuantity,
-				}
-				binanceOrderUSDT := models.BinancePlaceOrder{
-					Type:     "MARKET",
-					Symbol:   v.Symbol + "USDT",
-					Quantity: perQuantity,
-				}
+	return true
+}
 
-				if v.Direction {
-					binanceOrderBUSD.Side = "BUY"
-					binanceOrderUSDT.Side = "SELL"
-				} else {
-					binanceOrderBUSD.Side = "SELL"
-					binanceOrderUSDT.Side = "BUY"
-				}
+// LogError logs err and publishes an error event, a fatal err is kept
+// and the loop stops at its next check.
+func (c *Core) LogError(logger *logrus.Entry, err error) {
+	if err == nil {
+		return
+	}
 
-				if c.Setting.Reduce || fundingRateReverseMode {
-					binanceOrderBUSD.ReduceOnly = "true"
-					binanceOrderUSDT.ReduceOnly = "true"
-				}
+	logger.Error(err)
 
-				orders := make([]models.BinancePlaceOrder, 0)
-				orders = append(orders, binanceOrderBUSD)
-				orders = append(orders, binanceOrderUSDT)
-
-				// place binance order
-				if totalQuantity > 0 {
-					logger.Info("USDT BID=", usdtBid)
-					logger.Info("USDT ASK=", usdtAsk)
-					logger.Info("BUSD BID=", busdBid)
-					logger.Info("BUSD ASK=", busdAsk)
-
-					c.EventPublisher <- models.EventMessage{
-						Type:    "place",
-						Setting: c.Setting,
-						Message: map[string]float64{
-							"USDT_ASK_PRICE": usdtAsk,
-							"BUSD_ASK_PRICE": busdAsk,
-							"BUSD_BID_PRICE": busdBid,
-							"USDT_BID_PRICE": usdtBid,
-							"USDT_BID_SIZE":  usdtBidSize,
-							"USDT_ASK_SIZE":  usdtAskSize,
-							"BUSD_BID_SIZE":  busdBidSize,
-							"BUSD_ASK_SIZE":  busdAskSize,
-						},
-					}
-
-					batchOrders, _ := json.Marshal(orders)
-
-					logger.Info(string(batchOrders))
-
-					_, body, _ := c.MakeRequest(
-						BINANCE_FAPI_BATCH_ORDERS,
-						gorequest.POST,
-						map[string]string{
-							"batchOrders": string(batchOrders),
-						},
-					).End()
-					logrus.Info(string(body))
-
-					// update total
-					value := decimal.
-						NewFromFloat(quantityPerOrder).
-						Mul(decimal.NewFromInt(int64(step)))
-
-					// calculate totalQuantity
-					totalQuantity, _ = decimal.
-						NewFromFloat(totalQuantity).
-						Sub(value).
-						Float64()
-				}
+	// the same error every tick is only published once, until it's gone for a while
+	repeated := c.lastError != nil &&
+		c.lastError.Error() == err.Error() &&
+		time.Since(c.lastErrorTime) < ERROR_REPEAT_WINDOW
 
-				// exit loop
-				break
-			}
+	c.lastError = err
+	c.lastErrorTime = time.Now()
+
+	if IsFatal(err) {
+		if c.fatal == nil {
+			c.fatal = err
 		}
 
-		time.Sleep(1 * time.Second)
+		return
+	}
+
+	if !repeated {
+		c.Publish(models.EventMessage{Type: "error", Message: errorMessage(err, false)})
 	}
 }
 
+// ReceiveFatal reports whether a fatal error stops the bot, publishing an error event.
+func (c *Core) ReceiveFatal(logger *logrus.Entry) bool {
+	if c.fatal == nil {
+		return false
+	}
+
+	logger.Error("stop on fatal error")
+
+	c.Publish(models.EventMessage{Type: "error", Message: errorMessage(c.fatal, true)})
+
+	return true
+}
+
+// errorMessage is the message of an error event, with code & msg of Binance errors.
+func errorMessage(err error, fatal bool) map[string]any {
+	message := map[string]any{
+		"msg":   err.Error(),
+		"fatal": fatal,
+	}
+
+	var binanceErr *models.BinanceError
+
+	if errors.As(err, &binanceErr) {
+		message["code"] = binanceErr.Code
+		message["msg"] = binanceErr.Msg
+	}
+
+	return message
+}
+
+func (c *Core) GetHedge() []binance.BinanceHedge {
+	hedge, err := c.Provider.GetHedge()
+	if err != nil {
+		c.LogError(logrus.WithField("symbol", c.Setting.Symbol), err)
+	}
+
+	return hedge
+}
+
 func filter[T any](slice []T, f func(T) bool) []T {
 	var n []T
 	for _, e := range slice {
@@ -579,7 +955,6 @@ func filter[T any](slice []T, f func(T) bool) []T {
 	return n
 }
 
-// TODO
 func sum[T any](slice []T, f func(T) float64) float64 {
 	var n float64
 	for _, e := range slice {
@@ -0,0 +1,388 @@
+package modules
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
+
+	binance "github.com/CapsLock-Studio/binance-premium-index/models"
+)
+
+// RunSpot buys spot and shorts the perpetual while funding is positive.
+// Spot can't be shorted, so when funding flips both legs are unwound instead.
+func (c *Core) RunSpot(ctx context.Context, logger *logrus.Entry) {
+	symbol := c.Setting.Symbol + c.Setting.PrimaryQuote
+
+	logger = logger.WithField("mode", "spot")
+
+	if err := c.Exchange.SetLeverage(symbol, c.Setting.Leverage); err != nil {
+		c.LogError(logger, err)
+	}
+
+	// resume from checkpoint, otherwise from spot balance
+	if !c.Restore(logger) {
+		c.State = &models.CoreState{
+			Hedged: c.GetSpotPosition(logger),
+		}
+	}
+
+	wait := func() {
+		c.Checkpoint(logger)
+		c.UpdateStatus()
+		c.Wait(ctx)
+		c.ReceiveUserEvents(logger)
+	}
+
+	logger.Info("I'm trying to place some spot & perp orders...")
+
+	for {
+		c.RateLimiter.Take()
+
+		if c.ReceiveClose(logger) || c.ReceiveFatal(logger) || c.ReceiveShutdown(ctx, logger) {
+			break
+		}
+
+		c.ReceiveUpdates(logger)
+
+		if c.Paused() {
+			wait()
+			continue
+		}
+
+		c.CollectIncome(logger)
+		c.ReceiveSpotLiquidation(logger)
+
+		if c.Setting.Reduce && c.State.Hedged <= 0 {
+			break
+		}
+
+		premium := c.GetPremium(symbol)
+
+		if premium == nil {
+			wait()
+			continue
+		}
+
+		rate, _ := strconv.ParseFloat(premium.LastFundingRate, 64)
+		minutes := time.
+			UnixMilli(int64(premium.NextFundingTime)).
+			Sub(time.UnixMilli(int64(premium.Time))).
+			Minutes()
+
+		if rate >= 0 {
+			c.State.Unwinding = false
+		} else if c.State.Hedged > 0 && minutes < c.Setting.Before && !c.State.Unwinding {
+			logger.Info("funding rate flipped, unwind orders...")
+
+			c.State.Unwinding = true
+			c.Publish(models.EventMessage{Type: "reverse"})
+		}
+
+		opening := !c.Setting.Reduce && !c.State.Unwinding && rate > 0 && c.State.Hedged < c.Setting.Total
+		closing := (c.Setting.Reduce || c.State.Unwinding) && c.State.Hedged > 0
+
+		quantity := c.Setting.Quantity
+		left := c.Setting.Total - c.State.Hedged
+
+		if closing {
+			left = c.State.Hedged
+
+			// what's left can't be sold anymore
+			if c.RoundQuantity(left) <= 0 {
+				logger.Info("remaining hedge is less than lot size=", left)
+				c.State.Hedged = 0
+				wait()
+				continue
+			}
+		}
+
+		if quantity > left {
+			quantity = left
+		}
+
+		// commissions may have taken some of the spot leg
+		if closing {
+			free, err := c.Spot.GetFreeBalance(c.Setting.Symbol)
+			if err != nil {
+				c.LogError(logger, err)
+			} else if quantity > free {
+				quantity = free
+			}
+		}
+
+		quantity = c.RoundQuantity(quantity)
+
+		if (!opening && !closing) || quantity <= 0 {
+			wait()
+			continue
+		}
+
+		// annualized yield of a non-leveraged spot leg
+		yield := rate * 100 * 365 * 3
+
+		if opening && c.Setting.Threshold > yield {
+			logger.Info("threshold is greater then yield=", yield)
+			wait()
+			continue
+		}
+
+		spotBid, spotBidSize, spotAsk, spotAskSize, err := c.Spot.GetDepth(symbol)
+		if err != nil {
+			logger.Error(err)
+		}
+
+		perpBid, perpBidSize, perpAsk, perpAskSize := c.GetDepth(c.Setting.PrimaryQuote)
+
+		rules := []bool{
+			spotBidSize > quantity,
+			spotAskSize > quantity,
+			perpBidSize > quantity,
+			perpAskSize > quantity,
+		}
+
+		// don't pay more than difference for the spot leg
+		if opening && spotAsk > 0 {
+			rules = append(rules, (spotAsk-perpBid)/spotAsk*100 <= c.Setting.Difference)
+		}
+
+		if slices.Contains(rules, false) {
+			wait()
+			continue
+		}
+
+		perQuantity := decimal.NewFromFloat(quantity).String()
+
+		spotOrder := models.BinancePlaceOrder{
+			Type:     "MARKET",
+			Symbol:   symbol,
+			Quantity: perQuantity,
+			Side:     "BUY",
+		}
+		perpOrder := models.BinancePlaceOrder{
+			Type:     "MARKET",
+			Symbol:   symbol,
+			Quantity: perQuantity,
+			Side:     "SELL",
+		}
+
+		if closing {
+			spotOrder.Side = "SELL"
+			perpOrder.Side = "BUY"
+			perpOrder.ReduceOnly = "true"
+		}
+
+		c.Publish(models.EventMessage{
+			Type: "place",
+			Message: map[string]float64{
+				"SPOT_ASK_PRICE": spotAsk,
+				"SPOT_BID_PRICE": spotBid,
+				"PERP_ASK_PRICE": perpAsk,
+				"PERP_BID_PRICE": perpBid,
+			},
+		})
+
+		result, err := c.Spot.PlaceOrder(spotOrder)
+		if err != nil {
+			c.LogError(logger, err)
+		}
+
+		if c.CheckOrders(logger, []models.BinanceOrderResult{result}) == 0 {
+			wait()
+			continue
+		}
+
+		results, err := c.Exchange.PlaceBatchOrders([]models.BinancePlaceOrder{perpOrder})
+		if err != nil {
+			c.LogError(logger, err)
+		}
+
+		c.Journal(logger, "place", append(results, result))
+
+		filled := c.GetSpotFilled(result)
+
+		if c.CheckOrders(logger, results) == 0 {
+			// undo the spot leg so the position stays neutral
+			if spotOrder.Side == "BUY" {
+				spotOrder.Side = "SELL"
+				spotOrder.Quantity = decimal.NewFromFloat(c.RoundQuantity(filled)).String()
+			} else {
+				spotOrder.Side = "BUY"
+			}
+
+			c.Publish(models.EventMessage{
+				Type: "repair",
+				Message: map[string]any{
+					"symbol":   spotOrder.Symbol,
+					"side":     spotOrder.Side,
+					"quantity": spotOrder.Quantity,
+				},
+			})
+
+			result, err := c.Spot.PlaceOrder(spotOrder)
+			if err != nil {
+				c.LogError(logger, err)
+			}
+
+			c.Journal(logger, "repair", []models.BinanceOrderResult{result})
+
+			wait()
+			continue
+		}
+
+		value := decimal.NewFromFloat(filled)
+
+		if closing {
+			value = value.Neg()
+		}
+
+		c.State.Hedged, _ = decimal.
+			NewFromFloat(c.State.Hedged).
+			Add(value).
+			Float64()
+
+		if c.State.Hedged < 0 {
+			c.State.Hedged = 0
+		}
+
+		wait()
+	}
+}
+
+// ReceiveSpotLiquidation sells the spot a liquidated perp short no longer covers
+// and keeps the bot from opening it again, a failed sale is tried again next time.
+func (c *Core) ReceiveSpotLiquidation(logger *logrus.Entry) {
+	if c.liquidated <= 0 {
+		return
+	}
+
+	reduced := math.Min(c.liquidated, c.State.Hedged)
+
+	free, err := c.Spot.GetFreeBalance(c.Setting.Symbol)
+	if err != nil {
+		c.LogError(logger, err)
+		return
+	}
+
+	quantity := c.RoundQuantity(math.Min(reduced, free))
+
+	if quantity > 0 {
+		order := models.BinancePlaceOrder{
+			Type:     "MARKET",
+			Symbol:   c.Setting.Symbol + c.Setting.PrimaryQuote,
+			Side:     "SELL",
+			Quantity: decimal.NewFromFloat(quantity).String(),
+		}
+
+		c.Publish(models.EventMessage{
+			Type: "repair",
+			Message: map[string]any{
+				"symbol":   order.Symbol,
+				"side":     order.Side,
+				"quantity": order.Quantity,
+			},
+		})
+
+		result, err := c.Spot.PlaceOrder(order)
+		if err != nil {
+			c.LogError(logger, err)
+			return
+		}
+
+		c.Journal(logger, "repair", []models.BinanceOrderResult{result})
+	}
+
+	c.liquidated = 0
+
+	c.State.Hedged, _ = decimal.
+		NewFromFloat(c.State.Hedged).
+		Sub(decimal.NewFromFloat(reduced)).
+		Float64()
+
+	// don't reopen what the liquidation closed
+	if !c.Setting.Reduce {
+		c.Setting.Total, _ = decimal.
+			NewFromFloat(c.Setting.Total).
+			Sub(decimal.NewFromFloat(reduced)).
+			Float64()
+	}
+
+	if c.State.Hedged < 0 {
+		c.State.Hedged = 0
+	}
+
+	if c.Setting.Total < 0 {
+		c.Setting.Total = 0
+	}
+}
+
+// GetSpotFilled returns how much base asset a spot order moved,
+// buys pay their commission in the base asset unless it's paid otherwise.
+func (c *Core) GetSpotFilled(result models.BinanceOrderResult) float64 {
+	filled, err := decimal.NewFromString(result.ExecutedQty)
+	if err != nil {
+		filled, _ = decimal.NewFromString(result.OrigQty)
+	}
+
+	if result.Side == "BUY" {
+		for _, v := range result.Fills {
+			if v.CommissionAsset != c.Setting.Symbol {
+				continue
+			}
+
+			commission, _ := decimal.NewFromString(v.Commission)
+			filled = filled.Sub(commission)
+		}
+	}
+
+	value, _ := filled.Float64()
+
+	return value
+}
+
+// GetSpotPosition treats the spot balance covered by a perp short as the open position.
+func (c *Core) GetSpotPosition(logger *logrus.Entry) float64 {
+	symbol := c.Setting.Symbol + c.Setting.PrimaryQuote
+
+	balance, err := c.Spot.GetAssetBalance(c.Setting.Symbol)
+	if err != nil {
+		c.LogError(logger, err)
+	}
+
+	openPositions, err := c.Exchange.GetPositionRisk()
+	if err != nil {
+		c.LogError(logger, err)
+	}
+
+	for _, v := range filter(openPositions, func(v models.BinanceOrder) bool {
+		return v.Symbol == symbol
+	}) {
+		amount, _ := decimal.NewFromString(v.PositionAmt)
+
+		if amount.IsNegative() {
+			position, _ := decimal.Min(amount.Abs(), decimal.NewFromFloat(balance)).Float64()
+
+			return position
+		}
+	}
+
+	return 0
+}
+
+// GetPremium returns the premium index of a single perpetual symbol.
+func (c *Core) GetPremium(symbol string) *binance.BinancePremium {
+	for _, h := range c.GetHedge() {
+		for _, v := range h.Index {
+			if v.Symbol == symbol {
+				return &v
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package modules
+
+import (
+	"testing"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/ratelimit"
+)
+
+// fakeSpot holds a free balance and records orders.
+type fakeSpot struct {
+	Free   float64
+	Orders []models.BinancePlaceOrder
+}
+
+func (f *fakeSpot) GetDepth(symbol string) (bid, bidSize, ask, askSize float64, err error) {
+	return 0, 0, 0, 0, nil
+}
+
+func (f *fakeSpot) GetAssetBalance(asset string) (float64, error) {
+	return f.Free, nil
+}
+
+func (f *fakeSpot) GetFreeBalance(asset string) (float64, error) {
+	return f.Free, nil
+}
+
+func (f *fakeSpot) PlaceOrder(order models.BinancePlaceOrder) (models.BinanceOrderResult, error) {
+	f.Orders = append(f.Orders, order)
+
+	return models.BinanceOrderResult{Symbol: order.Symbol, Side: order.Side, OrigQty: order.Quantity, ExecutedQty: order.Quantity}, nil
+}
+
+func (f *fakeSpot) GetExchangeInfo() ([]models.BinanceSymbolInfo, error) {
+	return nil, nil
+}
+
+func TestGetSpotFilled(t *testing.T) {
+	setting := &models.ConfigSetting{Symbol: "LDO", Spot: true}
+	core := NewCore(setting, &fakeExchange{}, nil, ratelimit.NewUnlimited())
+
+	// the buy paid 0.1% of it as commission in LDO
+	assert.Equal(t, 9.98, core.GetSpotFilled(models.BinanceOrderResult{
+		Side:        "BUY",
+		OrigQty:     "10",
+		ExecutedQty: "10",
+		Fills: []models.BinanceTrade{
+			{Qty: "6", Commission: "0.006", CommissionAsset: "LDO"},
+			{Qty: "4", Commission: "0.004", CommissionAsset: "LDO"},
+			{Qty: "0", Commission: "0.01", CommissionAsset: "LDO"},
+		},
+	}))
+
+	assert.Equal(t, 10.0, core.GetSpotFilled(models.BinanceOrderResult{
+		Side:        "BUY",
+		ExecutedQty: "10",
+		Fills:       []models.BinanceTrade{{Qty: "10", Commission: "0.001", CommissionAsset: "BNB"}},
+	}))
+
+	// sells pay in the quote asset
+	assert.Equal(t, 5.0, core.GetSpotFilled(models.BinanceOrderResult{
+		Side:        "SELL",
+		ExecutedQty: "5",
+		Fills:       []models.BinanceTrade{{Qty: "5", Commission: "0.01", CommissionAsset: "USDT"}},
+	}))
+}
+
+func TestReceiveSpotLiquidation(t *testing.T) {
+	spot := &fakeSpot{Free: 2.5}
+
+	core := newTestCore(&fakeExchange{})
+	core.Setting.Spot = true
+	core.Spot = spot
+	core.State = &models.CoreState{Hedged: 6}
+
+	// 4 of the perp short were liquidated, the spot has less left than that
+	core.liquidated = 4
+	core.ReceiveSpotLiquidation(logrus.NewEntry(logrus.New()))
+
+	assert.Equal(t, []models.BinancePlaceOrder{{Type: "MARKET", Symbol: "LDOUSDT", Side: "SELL", Quantity: "2.5"}}, spot.Orders)
+	assert.Equal(t, 2.0, core.State.Hedged)
+	assert.Equal(t, 6.0, core.Setting.Total)
+	assert.Zero(t, core.liquidated)
+
+	// nothing happens without a liquidation
+	core.ReceiveSpotLiquidation(logrus.NewEntry(logrus.New()))
+	assert.Len(t, spot.Orders, 1)
+}
@@ -1,10 +1,15 @@
 package modules
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"log"
+	"time"
 
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
 	"github.com/google/uuid"
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -28,10 +33,16 @@ func NewDB(path string, secret string) *DB {
 	}
 
 	db.Exec("CREATE TABLE IF NOT EXISTS `states` (id varchar, user_id varchar, value text)")
+	db.Exec("CREATE TABLE IF NOT EXISTS `runtimes` (id varchar PRIMARY KEY, value text)")
+	db.Exec("CREATE TABLE IF NOT EXISTS `credentials` (id varchar PRIMARY KEY, user_id varchar, name varchar, api_key text, api_secret text, created_at integer, updated_at integer)")
+	db.Exec("CREATE TABLE IF NOT EXISTS `incomes` (bot_id varchar, user_id varchar, symbol varchar, type varchar, income real, asset varchar, time integer, tran_id integer, UNIQUE(bot_id, symbol, type, tran_id))")
+	db.Exec("CREATE TABLE IF NOT EXISTS `tokens` (id varchar PRIMARY KEY, user_id varchar, role varchar, hash varchar, created_at integer)")
+	db.Exec("CREATE TABLE IF NOT EXISTS `trades` (id varchar, batch_id varchar, bot_id varchar, user_id varchar, type varchar, symbol varchar, order_id integer, side varchar, quantity real, price real, fee real, fee_asset varchar, realized_pnl real, created_at integer)")
 
 	return &DB{
 		DB:     db,
 		Crypto: NewCrypto([]byte(secret)),
+		Secret: secret,
 	}
 }
 
@@ -76,24 +87,81 @@ func (d *DB) CreateUserState(userID string, value any) string {
 	return ID
 }
 
+func (d *DB) UpdateUserState(userID string, ID string, value any) error {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	// handle encrypt
+	v, err := d.Crypto.Encrypt(string(body))
+	if err != nil {
+		return err
+	}
+
+	_, err = d.DB.Exec("UPDATE `states` SET value=? WHERE user_id=? AND id=?", *v, userID, ID)
+
+	return err
+}
+
 func (d *DB) DropUserState(userID string, ID string) error {
+	d.DB.Exec("DELETE FROM `runtimes` WHERE id IN (SELECT id FROM `states` WHERE user_id=? AND id=?)", userID, ID)
+
 	_, err := d.DB.Exec("DELETE FROM `states` WHERE user_id=? AND id=?", userID, ID)
 
 	return err
 }
 
 func (d *DB) DropState(ID string) error {
-	_, err := d.DB.Exec("DELETE FROM `states` WHERE AND id=?", ID)
+	d.DB.Exec("DELETE FROM `runtimes` WHERE id=?", ID)
+
+	_, err := d.DB.Exec("DELETE FROM `states` WHERE id=?", ID)
 
 	return err
 }
 
 func (d *DB) DropUserStates(userID string) error {
+	d.DB.Exec("DELETE FROM `runtimes` WHERE id IN (SELECT id FROM `states` WHERE user_id=?)", userID)
+
 	_, err := d.DB.Exec("DELETE FROM `states` WHERE user_id=?", userID)
 
 	return err
 }
 
+func (d *DB) SaveRuntime(ID string, state *models.CoreState) error {
+	body, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.DB.Exec("INSERT OR REPLACE INTO `runtimes`(id, value) VALUES(?, ?)", ID, string(body))
+
+	return err
+}
+
+// GetRuntime returns nil when the bot has never checkpointed.
+func (d *DB) GetRuntime(ID string) (*models.CoreState, error) {
+	var value string
+
+	err := d.DB.QueryRow("SELECT value FROM `runtimes` WHERE id=?", ID).Scan(&value)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var state models.CoreState
+
+	if err := json.Unmarshal([]byte(value), &state); err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
 func (d *DB) GetUserSates(userID string) (result []State) {
 	rows, err := d.DB.Query("SELECT id, user_id, value FROM `states` WHERE user_id=?", userID)
 
@@ -119,3 +187,399 @@ func (d *DB) GetUserSates(userID string) (result []State) {
 
 	return
 }
+
+func (d *DB) CreateTrade(trade models.Trade) error {
+	_, err := d.DB.Exec(
+		"INSERT INTO `trades`(id, batch_id, bot_id, user_id, type, symbol, order_id, side, quantity, price, fee, fee_asset, realized_pnl, created_at) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		trade.ID,
+		trade.BatchID,
+		trade.BotID,
+		trade.UserID,
+		trade.Type,
+		trade.Symbol,
+		trade.OrderID,
+		trade.Side,
+		trade.Quantity,
+		trade.Price,
+		trade.Fee,
+		trade.FeeAsset,
+		trade.RealizedPnl,
+		trade.CreatedAt,
+	)
+
+	return err
+}
+
+func (d *DB) GetUserTrades(userID string, botID string) (result []models.Trade) {
+	rows, err := d.DB.Query(
+		"SELECT id, batch_id, bot_id, user_id, type, symbol, order_id, side, quantity, price, fee, fee_asset, realized_pnl, created_at FROM `trades` WHERE user_id=? AND bot_id=? ORDER BY created_at, rowid",
+		userID,
+		botID,
+	)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var trade models.Trade
+
+		if err := rows.Scan(
+			&trade.ID,
+			&trade.BatchID,
+			&trade.BotID,
+			&trade.UserID,
+			&trade.Type,
+			&trade.Symbol,
+			&trade.OrderID,
+			&trade.Side,
+			&trade.Quantity,
+			&trade.Price,
+			&trade.Fee,
+			&trade.FeeAsset,
+			&trade.RealizedPnl,
+			&trade.CreatedAt,
+		); err != nil {
+			continue
+		}
+
+		result = append(result, trade)
+	}
+
+	return
+}
+
+// IsUserBot reports whether the bot is running or has traded for the user.
+func (d *DB) IsUserBot(userID string, ID string) bool {
+	var count int
+
+	d.DB.QueryRow(
+		"SELECT (SELECT COUNT(*) FROM `states` WHERE user_id=? AND id=?) + (SELECT COUNT(*) FROM `trades` WHERE user_id=? AND bot_id=?)",
+		userID,
+		ID,
+		userID,
+		ID,
+	).Scan(&count)
+
+	return count > 0
+}
+
+func (d *DB) CreateIncome(income models.Income) error {
+	_, err := d.DB.Exec(
+		"INSERT OR IGNORE INTO `incomes`(bot_id, user_id, symbol, type, income, asset, time, tran_id) VALUES(?, ?, ?, ?, ?, ?, ?, ?)",
+		income.BotID,
+		income.UserID,
+		income.Symbol,
+		income.Type,
+		income.Income,
+		income.Asset,
+		income.Time,
+		income.TranID,
+	)
+
+	return err
+}
+
+// GetIncomeStart returns where to continue collecting income, 0 if the bot never traded.
+func (d *DB) GetIncomeStart(botID, symbol, incomeType string) int64 {
+	var last sql.NullInt64
+
+	d.DB.QueryRow("SELECT MAX(time) FROM `incomes` WHERE bot_id=? AND symbol=? AND type=?", botID, symbol, incomeType).Scan(&last)
+
+	if last.Valid {
+		return last.Int64 + 1
+	}
+
+	var first sql.NullInt64
+
+	d.DB.QueryRow("SELECT MIN(created_at) FROM `trades` WHERE bot_id=?", botID).Scan(&first)
+
+	return first.Int64
+}
+
+func (d *DB) GetUserIncomes(userID string, botID string) (result []models.Income) {
+	rows, err := d.DB.Query(
+		"SELECT bot_id, user_id, symbol, type, income, asset, time, tran_id FROM `incomes` WHERE user_id=? AND bot_id=? ORDER BY time",
+		userID,
+		botID,
+	)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var income models.Income
+
+		if err := rows.Scan(
+			&income.BotID,
+			&income.UserID,
+			&income.Symbol,
+			&income.Type,
+			&income.Income,
+			&income.Asset,
+			&income.Time,
+			&income.TranID,
+		); err != nil {
+			continue
+		}
+
+		result = append(result, income)
+	}
+
+	return
+}
+
+// CreateToken returns a new API token "<id>.<secret>" of the user.
+// Only the hash of the secret is kept, see TokenSecret.
+func (d *DB) CreateToken(userID, role string) (string, error) {
+	ID, err := randomHex(8)
+	if err != nil {
+		return "", err
+	}
+
+	secret := d.TokenSecret(ID)
+
+	_, err = d.DB.Exec(
+		"INSERT INTO `tokens`(id, user_id, role, hash, created_at) VALUES(?, ?, ?, ?, ?)",
+		ID,
+		userID,
+		role,
+		HashSecret(secret),
+		time.Now().UnixMilli(),
+	)
+
+	if err != nil {
+		return "", err
+	}
+
+	return ID + "." + secret, nil
+}
+
+// TokenSecret derives the secret of a token from the server secret, HMAC
+// signatures need it and the store can't give it back.
+func (d *DB) TokenSecret(ID string) string {
+	mac := hmac.New(sha256.New, []byte(d.Secret))
+	mac.Write([]byte("token:" + ID))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// GetToken returns nil when there is no such token.
+func (d *DB) GetToken(ID string) (*models.Token, error) {
+	var token models.Token
+
+	err := d.DB.QueryRow("SELECT id, user_id, role, hash, created_at FROM `tokens` WHERE id=?", ID).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Role,
+		&token.Hash,
+		&token.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (d *DB) GetUserTokens(userID string) (result []models.Token) {
+	rows, err := d.DB.Query("SELECT id, user_id, role, created_at FROM `tokens` WHERE user_id=? ORDER BY created_at", userID)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var token models.Token
+
+		if err := rows.Scan(&token.ID, &token.UserID, &token.Role, &token.CreatedAt); err != nil {
+			continue
+		}
+
+		result = append(result, token)
+	}
+
+	return
+}
+
+func (d *DB) DropUserToken(userID string, ID string) error {
+	_, err := d.DB.Exec("DELETE FROM `tokens` WHERE user_id=? AND id=?", userID, ID)
+
+	return err
+}
+
+func (d *DB) DropUserTokens(userID string) error {
+	_, err := d.DB.Exec("DELETE FROM `tokens` WHERE user_id=?", userID)
+
+	return err
+}
+
+// GetUsers returns every user with a token or a bot.
+func (d *DB) GetUsers() (result []string) {
+	rows, err := d.DB.Query("SELECT user_id FROM `tokens` UNION SELECT user_id FROM `states` ORDER BY user_id")
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID string
+
+		if err := rows.Scan(&userID); err != nil {
+			continue
+		}
+
+		result = append(result, userID)
+	}
+
+	return
+}
+
+// CreateCredential keeps both keys of the pair encrypted.
+func (d *DB) CreateCredential(userID string, credential models.Credential) (string, error) {
+	apiKey, err := d.Crypto.Encrypt(credential.ApiKey)
+	if err != nil {
+		return "", err
+	}
+
+	apiSecret, err := d.Crypto.Encrypt(credential.ApiSecret)
+	if err != nil {
+		return "", err
+	}
+
+	ID := uuid.New().String()
+	now := time.Now().UnixMilli()
+
+	_, err = d.DB.Exec(
+		"INSERT INTO `credentials`(id, user_id, name, api_key, api_secret, created_at, updated_at) VALUES(?, ?, ?, ?, ?, ?, ?)",
+		ID,
+		userID,
+		credential.Name,
+		*apiKey,
+		*apiSecret,
+		now,
+		now,
+	)
+
+	if err != nil {
+		return "", err
+	}
+
+	return ID, nil
+}
+
+// GetUserCredential returns nil when the user has no such credential.
+func (d *DB) GetUserCredential(userID string, ID string) (*models.Credential, error) {
+	row := d.DB.QueryRow("SELECT id, user_id, name, api_key, api_secret, created_at, updated_at FROM `credentials` WHERE user_id=? AND id=?", userID, ID)
+
+	credential, err := d.scanCredential(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return credential, err
+}
+
+func (d *DB) GetUserCredentials(userID string) (result []models.Credential) {
+	rows, err := d.DB.Query("SELECT id, user_id, name, api_key, api_secret, created_at, updated_at FROM `credentials` WHERE user_id=? ORDER BY created_at", userID)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		credential, err := d.scanCredential(rows)
+		if err != nil {
+			continue
+		}
+
+		result = append(result, *credential)
+	}
+
+	return
+}
+
+// RotateUserCredential replaces the key pair, running bots aren't touched.
+func (d *DB) RotateUserCredential(userID string, ID string, apiKey string, apiSecret string) error {
+	encryptedKey, err := d.Crypto.Encrypt(apiKey)
+	if err != nil {
+		return err
+	}
+
+	encryptedSecret, err := d.Crypto.Encrypt(apiSecret)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.DB.Exec(
+		"UPDATE `credentials` SET api_key=?, api_secret=?, updated_at=? WHERE user_id=? AND id=?",
+		*encryptedKey,
+		*encryptedSecret,
+		time.Now().UnixMilli(),
+		userID,
+		ID,
+	)
+
+	return err
+}
+
+func (d *DB) DropUserCredential(userID string, ID string) error {
+	_, err := d.DB.Exec("DELETE FROM `credentials` WHERE user_id=? AND id=?", userID, ID)
+
+	return err
+}
+
+func (d *DB) DropUserCredentials(userID string) error {
+	_, err := d.DB.Exec("DELETE FROM `credentials` WHERE user_id=?", userID)
+
+	return err
+}
+
+func (d *DB) scanCredential(row interface{ Scan(dest ...any) error }) (*models.Credential, error) {
+	var credential models.Credential
+
+	err := row.Scan(
+		&credential.ID,
+		&credential.UserID,
+		&credential.Name,
+		&credential.ApiKey,
+		&credential.ApiSecret,
+		&credential.CreatedAt,
+		&credential.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey, err := d.Crypto.Decrypt(credential.ApiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	apiSecret, err := d.Crypto.Decrypt(credential.ApiSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	credential.ApiKey = *apiKey
+	credential.ApiSecret = *apiSecret
+
+	return &credential, nil
+}
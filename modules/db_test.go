@@ -0,0 +1,126 @@
+package modules
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDB(t *testing.T) *DB {
+	db := NewDB(filepath.Join(t.TempDir(), "test.db"), "0123456789abcdef0123456789abcdef")
+	t.Cleanup(db.Close)
+
+	return db
+}
+
+func TestRuntime(t *testing.T) {
+	db := newTestDB(t)
+	ID := db.CreateUserState("user", models.ConfigSetting{Symbol: "LDO"})
+
+	state, err := db.GetRuntime(ID)
+	assert.NoError(t, err)
+	assert.Nil(t, state)
+
+	direction := true
+	assert.NoError(t, db.SaveRuntime(ID, &models.CoreState{CurrentDirection: &direction, Step: -1, TotalQuantity: 3}))
+	assert.NoError(t, db.SaveRuntime(ID, &models.CoreState{CurrentDirection: &direction, Step: 1, TotalQuantity: 2}))
+
+	state, err = db.GetRuntime(ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, state.Step)
+	assert.Equal(t, 2.0, state.TotalQuantity)
+	assert.True(t, *state.CurrentDirection)
+
+	assert.NoError(t, db.DropState(ID))
+	assert.Empty(t, db.GetSates())
+
+	state, _ = db.GetRuntime(ID)
+	assert.Nil(t, state)
+}
+
+func TestUpdateUserState(t *testing.T) {
+	db := newTestDB(t)
+	ID := db.CreateUserState("user", models.ConfigSetting{Symbol: "LDO", Quantity: 1})
+
+	assert.NoError(t, db.UpdateUserState("other", ID, models.ConfigSetting{Symbol: "LDO", Quantity: 3}))
+	assert.NoError(t, db.UpdateUserState("user", ID, models.ConfigSetting{Symbol: "LDO", Quantity: 2, Paused: true}))
+
+	states := db.GetUserSates("user")
+
+	var setting models.ConfigSetting
+
+	assert.Len(t, states, 1)
+	assert.NoError(t, json.Unmarshal([]byte(states[0].Value), &setting))
+	assert.Equal(t, 2.0, setting.Quantity)
+	assert.True(t, setting.Paused)
+}
+
+func TestTokens(t *testing.T) {
+	db := newTestDB(t)
+
+	value, err := db.CreateToken("user", ROLE_ADMIN)
+	assert.NoError(t, err)
+
+	ID, secret, ok := strings.Cut(value, ".")
+	assert.True(t, ok)
+
+	token, err := db.GetToken(ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "user", token.UserID)
+	assert.Equal(t, ROLE_ADMIN, token.Role)
+	assert.Equal(t, HashSecret(secret), token.Hash)
+	assert.Equal(t, secret, db.TokenSecret(ID))
+
+	// the secret itself isn't stored
+	var columns int
+	db.DB.QueryRow("SELECT count(*) FROM pragma_table_info('tokens') WHERE name='secret'").Scan(&columns)
+	assert.Equal(t, 0, columns)
+
+	db.CreateUserState("other", models.ConfigSetting{Symbol: "LDO"})
+	assert.Equal(t, []string{"other", "user"}, db.GetUsers())
+	assert.Len(t, db.GetUserTokens("user"), 1)
+
+	assert.NoError(t, db.DropUserToken("other", ID))
+	assert.Len(t, db.GetUserTokens("user"), 1)
+
+	assert.NoError(t, db.DropUserTokens("user"))
+
+	token, err = db.GetToken(ID)
+	assert.NoError(t, err)
+	assert.Nil(t, token)
+}
+
+func TestCredentials(t *testing.T) {
+	db := newTestDB(t)
+
+	ID, err := db.CreateCredential("user", models.Credential{Name: "main", ApiKey: "key", ApiSecret: "0123456789secret"})
+	assert.NoError(t, err)
+
+	credential, err := db.GetUserCredential("other", ID)
+	assert.NoError(t, err)
+	assert.Nil(t, credential)
+
+	assert.NoError(t, db.RotateUserCredential("user", ID, "new", "new-0123456789"))
+
+	credential, err = db.GetUserCredential("user", ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "main", credential.Name)
+	assert.Equal(t, "new", credential.ApiKey)
+	assert.Equal(t, "new-0123456789", credential.ApiSecret)
+
+	credentials := db.GetUserCredentials("user")
+	assert.Len(t, credentials, 1)
+	assert.Equal(t, "****6789", credentials[0].Masked().ApiSecret)
+
+	// stored encrypted
+	var apiSecret string
+	db.DB.QueryRow("SELECT api_secret FROM `credentials` WHERE id=?", ID).Scan(&apiSecret)
+	assert.NotContains(t, apiSecret, "0123456789")
+
+	assert.NoError(t, db.DropUserCredential("user", ID))
+	assert.Empty(t, db.GetUserCredentials("user"))
+}
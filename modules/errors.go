@@ -0,0 +1,80 @@
+package modules
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/parnurzeal/gorequest"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	BINANCE_MAX_RETRIES   int           = 3
+	BINANCE_RETRY_BACKOFF time.Duration = 500 * time.Millisecond
+
+	BINANCE_INVALID_TIMESTAMP int = -1021
+
+	// an error seen again after this long is a new incident
+	ERROR_REPEAT_WINDOW time.Duration = time.Minute
+)
+
+// BINANCE_RETRYABLE_CODES are transient, the same request may pass on retry.
+var BINANCE_RETRYABLE_CODES = []int{
+	-1000,                     // UNKNOWN
+	-1001,                     // DISCONNECTED
+	-1003,                     // TOO_MANY_REQUESTS
+	-1006,                     // UNEXPECTED_RESP
+	-1007,                     // TIMEOUT
+	-1008,                     // SERVER_BUSY
+	BINANCE_INVALID_TIMESTAMP, // signed again on retry
+}
+
+// BINANCE_FATAL_CODES mean the key can't trade, the bot won't recover on its own.
+var BINANCE_FATAL_CODES = []int{
+	-1002, // UNAUTHORIZED
+	-1022, // INVALID_SIGNATURE
+	-2014, // BAD_API_KEY_FMT
+	-2015, // REJECTED_MBX_KEY, invalid key, IP or permissions
+}
+
+// BINANCE_IDEMPOTENT_PATHS may be retried even though they're POST.
+var BINANCE_IDEMPOTENT_PATHS = []string{
+	BINANCE_FAPI_LEVERAGE,
+	BINANCE_FAPI_LISTEN_KEY,
+}
+
+// IsRetryable reports whether err is a network failure or a transient Binance error.
+func IsRetryable(err error) bool {
+	var binanceErr *models.BinanceError
+
+	if errors.As(err, &binanceErr) {
+		return binanceErr.Status == RATE_LIMIT_RESPONSE ||
+			binanceErr.Status >= http.StatusInternalServerError ||
+			slices.Contains(BINANCE_RETRYABLE_CODES, binanceErr.Code)
+	}
+
+	var netErr net.Error
+
+	return errors.As(err, &netErr)
+}
+
+// IsFatal reports whether err means the bot has to stop.
+func IsFatal(err error) bool {
+	var binanceErr *models.BinanceError
+
+	if errors.As(err, &binanceErr) {
+		return binanceErr.Status == http.StatusUnauthorized ||
+			slices.Contains(BINANCE_FATAL_CODES, binanceErr.Code)
+	}
+
+	return false
+}
+
+// isIdempotent reports whether sending the request twice has the same effect as once,
+// orders and margin changes are never retried.
+func isIdempotent(path, method string) bool {
+	return method != gorequest.POST || slices.Contains(BINANCE_IDEMPOTENT_PATHS, path)
+}
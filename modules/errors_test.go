@@ -0,0 +1,63 @@
+package modules
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/ratelimit"
+)
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, IsRetryable(&models.BinanceError{Status: 400, Code: -1021, Msg: "Timestamp for this request is outside of the recvWindow."}))
+	assert.True(t, IsRetryable(&models.BinanceError{Status: 502, Msg: "Bad Gateway"}))
+	assert.False(t, IsRetryable(&models.BinanceError{Status: 400, Code: -2019, Msg: "Margin is insufficient."}))
+	assert.False(t, IsRetryable(&models.BinanceError{Status: 418, Msg: "I'm a teapot"}))
+	assert.False(t, IsRetryable(errors.New("unexpected end of JSON input")))
+}
+
+func TestReceiveFatal(t *testing.T) {
+	setting := &models.ConfigSetting{Symbol: "LDO"}
+	core := NewCore(setting, &fakeExchange{}, nil, ratelimit.NewUnlimited())
+	logger := logrus.NewEntry(logrus.New())
+
+	events := make(chan models.EventMessage, 10)
+
+	go func() {
+		for v := range core.GetPublisher() {
+			events <- v
+		}
+	}()
+
+	assert.False(t, core.ReceiveFatal(logger))
+
+	// the same error twice is published once
+	core.LogError(logger, &models.BinanceError{Code: -2019, Msg: "Margin is insufficient."})
+	core.LogError(logger, &models.BinanceError{Code: -2019, Msg: "Margin is insufficient."})
+
+	assert.False(t, core.ReceiveFatal(logger))
+	assert.Equal(t, models.EventMessage{
+		Type:    "error",
+		Symbol:  "LDO",
+		Message: map[string]any{"code": -2019, "msg": "Margin is insufficient.", "fatal": false},
+	}, <-events)
+
+	// it went away and came back
+	core.lastErrorTime = time.Now().Add(-ERROR_REPEAT_WINDOW)
+	core.LogError(logger, &models.BinanceError{Code: -2019, Msg: "Margin is insufficient."})
+
+	assert.Equal(t, "error", (<-events).Type)
+
+	core.LogError(logger, &models.BinanceError{Status: 401, Code: -2015, Msg: "Invalid API-key, IP, or permissions for action."})
+
+	assert.True(t, core.ReceiveFatal(logger))
+	assert.Equal(t, models.EventMessage{
+		Type:    "error",
+		Symbol:  "LDO",
+		Message: map[string]any{"code": -2015, "msg": "Invalid API-key, IP, or permissions for action.", "fatal": true},
+	}, <-events)
+	assert.Empty(t, events)
+}
@@ -0,0 +1,25 @@
+package modules
+
+import "github.com/CapsLock-Studio/binance-premium-bot/models"
+
+// Exchange is everything Core needs from a futures venue.
+type Exchange interface {
+	GetDepth(symbol string) (bid, bidSize, ask, askSize float64, err error)
+	GetPositionRisk() ([]models.BinanceOrder, error)
+	SetLeverage(symbol string, leverage int) error
+	PlaceBatchOrders(orders []models.BinancePlaceOrder) ([]models.BinanceOrderResult, error)
+	GetBalance() ([]models.BinanceBalance, error)
+	GetAccount() (models.BinanceAccount, error)
+	ModifyMargin(symbol string, amount float64, add bool) error
+	GetExchangeInfo() ([]models.BinanceSymbolInfo, error)
+	GetOrderTrades(symbol string, orderID int64) ([]models.BinanceTrade, error)
+	GetIncome(symbol, incomeType string, startTime int64) ([]models.BinanceIncome, error)
+}
+
+func firstError(errs []error) error {
+	if len(errs) > 0 {
+		return errs[0]
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package modules
+
+import (
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"go.uber.org/ratelimit"
+)
+
+// newTestCore is an LDO USDT/USDC bot whose events wait in a buffer.
+func newTestCore(exchange Exchange) *Core {
+	setting := &models.ConfigSetting{Symbol: "LDO", Quantity: 1, Total: 10}
+	setting.PrimaryQuote = "USDT"
+	setting.SecondaryQuote = "USDC"
+
+	core := NewCore(setting, exchange, nil, ratelimit.NewUnlimited())
+	core.EventPublisher = make(chan models.EventMessage, 100)
+
+	return core
+}
+
+// fakeExchange records orders instead of sending them.
+type fakeExchange struct {
+	Depth     map[string][4]float64
+	Positions []models.BinanceOrder
+	Account   models.BinanceAccount
+	Orders    []models.BinancePlaceOrder
+	Rejects   map[string]bool
+	Infos     []models.BinanceSymbolInfo
+	Fills     map[int64][]models.BinanceTrade
+	Incomes   []models.BinanceIncome
+	Margins   map[string]float64
+}
+
+func (f *fakeExchange) GetDepth(symbol string) (bid, bidSize, ask, askSize float64, err error) {
+	d := f.Depth[symbol]
+
+	return d[0], d[1], d[2], d[3], nil
+}
+
+func (f *fakeExchange) GetPositionRisk() ([]models.BinanceOrder, error) {
+	return f.Positions, nil
+}
+
+func (f *fakeExchange) SetLeverage(symbol string, leverage int) error {
+	return nil
+}
+
+func (f *fakeExchange) PlaceBatchOrders(orders []models.BinancePlaceOrder) ([]models.BinanceOrderResult, error) {
+	results := make([]models.BinanceOrderResult, 0)
+
+	for _, v := range orders {
+		if f.Rejects[v.Symbol] {
+			results = append(results, models.BinanceOrderResult{Code: -2019, Msg: "Margin is insufficient."})
+			continue
+		}
+
+		f.Orders = append(f.Orders, v)
+		results = append(results, models.BinanceOrderResult{
+			OrderID: int64(len(f.Orders)),
+			Symbol:  v.Symbol,
+			Side:    v.Side,
+			Status:  "NEW",
+			OrigQty: v.Quantity,
+		})
+	}
+
+	return results, nil
+}
+
+func (f *fakeExchange) GetBalance() ([]models.BinanceBalance, error) {
+	return nil, nil
+}
+
+func (f *fakeExchange) GetAccount() (models.BinanceAccount, error) {
+	return f.Account, nil
+}
+
+func (f *fakeExchange) GetExchangeInfo() ([]models.BinanceSymbolInfo, error) {
+	return f.Infos, nil
+}
+
+func (f *fakeExchange) GetOrderTrades(symbol string, orderID int64) ([]models.BinanceTrade, error) {
+	return f.Fills[orderID], nil
+}
+
+func (f *fakeExchange) GetIncome(symbol, incomeType string, startTime int64) ([]models.BinanceIncome, error) {
+	return filter(f.Incomes, func(v models.BinanceIncome) bool {
+		return v.Symbol == symbol && v.IncomeType == incomeType && v.Time >= startTime
+	}), nil
+}
+
+func (f *fakeExchange) ModifyMargin(symbol string, amount float64, add bool) error {
+	if f.Margins == nil {
+		f.Margins = make(map[string]float64)
+	}
+
+	if !add {
+		amount = -amount
+	}
+
+	f.Margins[symbol] += amount
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package modules
+
+import (
+	"github.com/shopspring/decimal"
+
+	binance "github.com/CapsLock-Studio/binance-premium-index/models"
+)
+
+// NewHedge rebuilds the hedge figures of symbol for the given quote pair.
+// It returns nil when either leg is missing from premiums.
+func NewHedge(
+	symbol,
+	primary,
+	secondary string,
+	premiums []binance.BinancePremium,
+) *binance.BinanceHedge {
+	var indexPrimary *binance.BinancePremium
+	var indexSecondary *binance.BinancePremium
+
+	for i := range premiums {
+		switch premiums[i].Symbol {
+		case symbol + primary:
+			indexPrimary = &premiums[i]
+		case symbol + secondary:
+			indexSecondary = &premiums[i]
+		}
+	}
+
+	if indexPrimary == nil || indexSecondary == nil {
+		return nil
+	}
+
+	ratePrimary, _ := decimal.NewFromString(indexPrimary.LastFundingRate)
+	rateSecondary, _ := decimal.NewFromString(indexSecondary.LastFundingRate)
+	markPricePrimary, _ := decimal.NewFromString(indexPrimary.MarkPrice)
+	markPriceSecondary, _ := decimal.NewFromString(indexSecondary.MarkPrice)
+
+	fundingRateGap, _ := ratePrimary.
+		Sub(rateSecondary).
+		Mul(decimal.NewFromInt(100)).
+		Abs().
+		Float64()
+
+	hedge := &binance.BinanceHedge{
+		Symbol:         symbol,
+		FundingRateGap: fundingRateGap,
+		Index:          []binance.BinancePremium{*indexPrimary, *indexSecondary},
+		Direction:      ratePrimary.GreaterThan(rateSecondary),
+	}
+
+	if !markPricePrimary.IsZero() {
+		hedge.MarkPriceGap, _ = markPricePrimary.
+			Sub(markPriceSecondary).
+			Div(markPricePrimary).
+			Mul(decimal.NewFromInt(100)).
+			Abs().
+			Float64()
+	}
+
+	if fundingRateGap > 0 {
+		hedge.ProfitForTimes = decimal.
+			NewFromFloat(hedge.MarkPriceGap).
+			Div(decimal.NewFromFloat(fundingRateGap)).
+			Ceil().
+			IntPart()
+	}
+
+	return hedge
+}
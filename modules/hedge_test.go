@@ -0,0 +1,27 @@
+package modules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	binance "github.com/CapsLock-Studio/binance-premium-index/models"
+)
+
+func TestNewHedge(t *testing.T) {
+	premiums := []binance.BinancePremium{
+		{Symbol: "LDOUSDT", MarkPrice: "2.00", LastFundingRate: "0.0003"},
+		{Symbol: "LDOBUSD", MarkPrice: "1.50", LastFundingRate: "0.0009"},
+		{Symbol: "LDOUSDC", MarkPrice: "1.99", LastFundingRate: "0.0001"},
+	}
+
+	hedge := NewHedge("LDO", "USDT", "USDC", premiums)
+
+	assert.NotNil(t, hedge)
+	assert.True(t, hedge.Direction)
+	assert.Equal(t, 0.02, hedge.FundingRateGap)
+	assert.Equal(t, 0.5, hedge.MarkPriceGap)
+	assert.Equal(t, 1.99, hedge.GetPrice("USDC"))
+
+	assert.Nil(t, NewHedge("LDO", "USDT", "FDUSD", premiums))
+}
@@ -0,0 +1,222 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/sirupsen/logrus"
+
+	binance "github.com/CapsLock-Studio/binance-premium-index/models"
+)
+
+const (
+	HUB_INTERVAL time.Duration = 1 * time.Second
+	HUB_STALE    time.Duration = 10 * time.Second
+)
+
+// Hub polls premium index once and depth once per symbol for every bot,
+// then fans the market data out to subscriptions.
+type Hub struct {
+	Exchange      *Binance
+	Interval      time.Duration
+	subscriptions map[*Subscription]bool
+	mutex         sync.Mutex
+}
+
+func NewHub(exchange *Binance) *Hub {
+	return &Hub{
+		Exchange:      exchange,
+		Interval:      HUB_INTERVAL,
+		subscriptions: make(map[*Subscription]bool),
+	}
+}
+
+// Subscribe starts delivering premium index and depth of symbols.
+func (h *Hub) Subscribe(symbols ...string) *Subscription {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	s := &Subscription{
+		Symbols: symbols,
+		C:       make(chan models.MarketData, 1),
+		hub:     h,
+	}
+
+	h.subscriptions[s] = true
+
+	return s
+}
+
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	delete(h.subscriptions, s)
+}
+
+func (h *Hub) Run() {
+	for {
+		h.Poll()
+
+		time.Sleep(h.Interval)
+	}
+}
+
+// Poll fetches market data of all subscribed symbols and delivers it.
+func (h *Hub) Poll() {
+	h.mutex.Lock()
+
+	subscriptions := make([]*Subscription, 0, len(h.subscriptions))
+	symbols := make(map[string]bool)
+
+	for s := range h.subscriptions {
+		subscriptions = append(subscriptions, s)
+
+		for _, symbol := range s.Symbols {
+			symbols[symbol] = true
+		}
+	}
+
+	h.mutex.Unlock()
+
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	now := time.Now().UnixMilli()
+
+	premiums, err := h.Exchange.GetPremiumIndex()
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	depth := make(map[string]models.Depth)
+
+	wg := &sync.WaitGroup{}
+	mutex := &sync.Mutex{}
+
+	for symbol := range symbols {
+		wg.Add(1)
+		go func(symbol string) {
+			defer wg.Done()
+
+			bid, bidSize, ask, askSize, err := h.Exchange.GetDepth(symbol)
+			if err != nil {
+				logrus.WithField("symbol", symbol).Error(err)
+				return
+			}
+
+			mutex.Lock()
+			depth[symbol] = models.Depth{Bid: bid, BidSize: bidSize, Ask: ask, AskSize: askSize}
+			mutex.Unlock()
+		}(symbol)
+	}
+
+	wg.Wait()
+
+	for _, s := range subscriptions {
+		data := models.MarketData{
+			Time:     now,
+			Premiums: premiums,
+			Depth:    make(map[string]models.Depth),
+		}
+
+		for _, symbol := range s.Symbols {
+			if v, ok := depth[symbol]; ok {
+				data.Depth[symbol] = v
+			}
+		}
+
+		s.deliver(data)
+	}
+}
+
+// Subscription keeps the latest market data delivered by the hub.
+type Subscription struct {
+	Symbols []string
+	C       chan models.MarketData
+	latest  models.MarketData
+	hub     *Hub
+	mutex   sync.Mutex
+}
+
+// deliver replaces undelivered data, subscribers only care about the latest.
+func (s *Subscription) deliver(data models.MarketData) {
+	for {
+		select {
+		case s.C <- data:
+			return
+		default:
+		}
+
+		select {
+		case <-s.C:
+		default:
+		}
+	}
+}
+
+// Latest returns the newest market data, or an error when it's missing or stale.
+func (s *Subscription) Latest() (models.MarketData, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	select {
+	case data := <-s.C:
+		s.latest = data
+	default:
+	}
+
+	if s.latest.Time == 0 {
+		return s.latest, errors.New("no market data yet")
+	}
+
+	if age := time.Since(time.UnixMilli(s.latest.Time)); age > HUB_STALE {
+		return s.latest, fmt.Errorf("market data is stale for %s", age.Truncate(time.Second))
+	}
+
+	return s.latest, nil
+}
+
+func (s *Subscription) Close() {
+	s.hub.Unsubscribe(s)
+}
+
+// HubExchange reads market data from a hub subscription, and from Binance
+// directly while the subscription has nothing fresh.
+type HubExchange struct {
+	*Binance
+	Subscription *Subscription
+}
+
+func (e *HubExchange) GetDepth(
+	symbol string,
+) (
+	bid,
+	bidSize,
+	ask,
+	askSize float64,
+	err error,
+) {
+	data, err := e.Subscription.Latest()
+
+	if v, ok := data.Depth[symbol]; ok && err == nil {
+		return v.Bid, v.BidSize, v.Ask, v.AskSize, nil
+	}
+
+	return e.Binance.GetDepth(symbol)
+}
+
+func (e *HubExchange) GetPremiumIndex() ([]binance.BinancePremium, error) {
+	data, err := e.Subscription.Latest()
+	if err != nil {
+		logrus.Warn(err)
+		return e.Binance.GetPremiumIndex()
+	}
+
+	return data.Premiums, nil
+}
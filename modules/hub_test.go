@@ -0,0 +1,91 @@
+package modules
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHubPoll(t *testing.T) {
+	requests := make(map[string]int)
+	mutex := &sync.Mutex{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests[r.URL.Path+"?"+r.URL.Query().Get("symbol")] += 1
+		mutex.Unlock()
+
+		switch r.URL.Path {
+		case BINANCE_FAPI_PREMIUM_INDEX:
+			w.Write([]byte(`[{"symbol":"LDOUSDT","markPrice":"2"},{"symbol":"LDOUSDC","markPrice":"2"}]`))
+		case BINANCE_FAPI_DEPTH:
+			w.Write([]byte(`{"bids":[["1.9","10"]],"asks":[["2.1","20"]]}`))
+		}
+	}))
+	defer server.Close()
+
+	b := NewBinance("", "")
+	b.Endpoint = server.URL
+
+	hub := NewHub(b)
+
+	// nothing is polled without subscribers
+	hub.Poll()
+	assert.Empty(t, requests)
+
+	first := hub.Subscribe("LDOUSDT", "LDOUSDC")
+	second := hub.Subscribe("LDOUSDT")
+
+	_, err := first.Latest()
+	assert.EqualError(t, err, "no market data yet")
+
+	hub.Poll()
+	hub.Poll()
+
+	// one request per symbol per poll, whatever the number of bots
+	assert.Equal(t, 2, requests[BINANCE_FAPI_PREMIUM_INDEX+"?"])
+	assert.Equal(t, 2, requests[BINANCE_FAPI_DEPTH+"?LDOUSDT"])
+	assert.Equal(t, 2, requests[BINANCE_FAPI_DEPTH+"?LDOUSDC"])
+
+	data, err := second.Latest()
+	assert.NoError(t, err)
+	assert.Len(t, data.Premiums, 2)
+	assert.Len(t, data.Depth, 1)
+	assert.Equal(t, 20.0, data.Depth["LDOUSDT"].AskSize)
+
+	exchange := &HubExchange{Binance: b, Subscription: first}
+
+	bid, _, _, _, err := exchange.GetDepth("LDOUSDC")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.9, bid)
+	assert.Equal(t, 2, requests[BINANCE_FAPI_DEPTH+"?LDOUSDC"])
+
+	second.Close()
+	hub.Poll()
+
+	assert.Equal(t, 3, requests[BINANCE_FAPI_DEPTH+"?LDOUSDC"])
+	assert.Equal(t, 3, requests[BINANCE_FAPI_DEPTH+"?LDOUSDT"])
+}
+
+func TestSubscriptionStale(t *testing.T) {
+	hub := NewHub(NewBinance("", ""))
+	s := hub.Subscribe("LDOUSDT")
+
+	s.deliver(models.MarketData{Time: time.Now().Add(-time.Minute).UnixMilli()})
+
+	// only the latest data is kept
+	s.deliver(models.MarketData{Time: time.Now().Add(-2 * HUB_STALE).UnixMilli()})
+
+	_, err := s.Latest()
+	assert.ErrorContains(t, err, "market data is stale for 20s")
+
+	s.deliver(models.MarketData{Time: time.Now().UnixMilli()})
+
+	_, err = s.Latest()
+	assert.NoError(t, err)
+}
@@ -0,0 +1,157 @@
+package modules
+
+import (
+	"math"
+	"time"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	INCOME_INTERVAL   time.Duration = 10 * time.Minute
+	INCOME_LIMIT      int           = 1000
+	INCOME_FUNDING    string        = "FUNDING_FEE"
+	INCOME_COMMISSION string        = "COMMISSION"
+)
+
+// IncomeStore keeps funding fees and commissions attributed to bots.
+type IncomeStore interface {
+	GetIncomeStart(botID, symbol, incomeType string) int64
+	CreateIncome(income models.Income) error
+}
+
+// CollectIncome pulls funding fees and commissions of the bot's perpetuals
+// since the last collected one, or since the first trade of the bot.
+// Bots trading the same symbol on one account get the same income.
+func (c *Core) CollectIncome(logger *logrus.Entry) {
+	if c.Incomes == nil || c.ID == nil || time.Since(c.lastIncome) < INCOME_INTERVAL {
+		return
+	}
+
+	c.lastIncome = time.Now()
+
+	for _, symbol := range c.GetSymbols() {
+		for _, incomeType := range []string{INCOME_FUNDING, INCOME_COMMISSION} {
+			startTime := c.Incomes.GetIncomeStart(*c.ID, symbol, incomeType)
+
+			// nothing traded yet
+			if startTime == 0 {
+				continue
+			}
+
+			for {
+				incomes, err := c.Exchange.GetIncome(symbol, incomeType, startTime)
+				if err != nil {
+					c.LogError(logger, err)
+					break
+				}
+
+				for _, v := range incomes {
+					income, _ := decimal.NewFromString(v.Income)
+					value, _ := income.Float64()
+
+					if err := c.Incomes.CreateIncome(models.Income{
+						BotID:  *c.ID,
+						UserID: c.Setting.UserID,
+						Symbol: v.Symbol,
+						Type:   v.IncomeType,
+						Income: value,
+						Asset:  v.Asset,
+						Time:   v.Time,
+						TranID: v.TranID,
+					}); err != nil {
+						logger.Error(err)
+					}
+
+					startTime = v.Time + 1
+				}
+
+				if len(incomes) < INCOME_LIMIT {
+					break
+				}
+			}
+		}
+	}
+}
+
+// NewStats sums up what a bot earned. Capital is the margin of the largest
+// position seen in the journal, setting is nil once the bot has stopped and
+// then capital is counted without leverage.
+func NewStats(setting *models.ConfigSetting, trades []models.Trade, incomes []models.Income) models.Stats {
+	stats := models.Stats{
+		Leverage: 1,
+	}
+
+	spot := false
+
+	if setting != nil && setting.Leverage > 0 {
+		stats.Leverage = setting.Leverage
+		spot = setting.Spot
+	}
+
+	stats.Funding = sum(filter(incomes, func(v models.Income) bool {
+		return v.Type == INCOME_FUNDING
+	}), func(v models.Income) float64 {
+		return v.Income
+	})
+
+	// commissions are negative income
+	stats.Fees = -sum(filter(incomes, func(v models.Income) bool {
+		return v.Type == INCOME_COMMISSION
+	}), func(v models.Income) float64 {
+		return v.Income
+	})
+
+	stats.RealizedPnl = sum(trades, func(v models.Trade) float64 {
+		return v.RealizedPnl
+	})
+
+	stats.Net, _ = decimal.
+		NewFromFloat(stats.Funding).
+		Sub(decimal.NewFromFloat(stats.Fees)).
+		Add(decimal.NewFromFloat(stats.RealizedPnl)).
+		Float64()
+
+	if len(trades) == 0 {
+		return stats
+	}
+
+	// follow one leg to find the largest position
+	position := decimal.Zero
+	peak := decimal.Zero
+
+	for _, v := range trades {
+		if v.Symbol != trades[0].Symbol {
+			continue
+		}
+
+		quantity := decimal.NewFromFloat(v.Quantity)
+
+		if v.Side == "BUY" {
+			position = position.Add(quantity)
+		} else {
+			position = position.Sub(quantity)
+		}
+
+		peak = decimal.Max(peak, position.Abs().Mul(decimal.NewFromFloat(v.Price)))
+	}
+
+	margin := peak.Div(decimal.NewFromInt(int64(stats.Leverage)))
+
+	// spot leg is paid in full
+	if spot {
+		stats.Capital, _ = peak.Add(margin).Float64()
+	} else {
+		stats.Capital, _ = margin.Mul(decimal.NewFromInt(2)).Float64()
+	}
+
+	stats.Days = time.Since(time.UnixMilli(trades[0].CreatedAt)).Hours() / 24
+
+	if stats.Capital > 0 && stats.Days > 0 {
+		stats.Apr = math.Round(stats.Net/stats.Capital/stats.Days*365*100*100) / 100
+	}
+
+	return stats
+}
@@ -0,0 +1,53 @@
+package modules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectIncome(t *testing.T) {
+	db := newTestDB(t)
+	start := time.Now().Add(-48 * time.Hour).UnixMilli()
+
+	exchange := &fakeExchange{
+		Incomes: []models.BinanceIncome{
+			{Symbol: "LDOUSDT", IncomeType: INCOME_FUNDING, Income: "0.5", Time: start - 1, TranID: 1},
+			{Symbol: "LDOUSDT", IncomeType: INCOME_FUNDING, Income: "0.6", Time: start + 1, TranID: 2},
+			{Symbol: "LDOUSDC", IncomeType: INCOME_FUNDING, Income: "-0.2", Time: start + 1, TranID: 3},
+			{Symbol: "LDOUSDT", IncomeType: INCOME_COMMISSION, Income: "-0.1", Time: start + 2, TranID: 4},
+		},
+	}
+
+	ID := "bot"
+	core := newTestCore(exchange)
+	core.ID = &ID
+	core.Setting.UserID = "user"
+	core.Setting.Leverage = 2
+	core.Incomes = db
+
+	db.CreateTrade(models.Trade{BotID: ID, UserID: "user", Symbol: "LDOUSDT", Side: "SELL", Quantity: 10, Price: 2, CreatedAt: start})
+	db.CreateTrade(models.Trade{BotID: ID, UserID: "user", Symbol: "LDOUSDC", Side: "BUY", Quantity: 10, Price: 2, CreatedAt: start})
+
+	logger := logrus.NewEntry(logrus.New())
+	core.CollectIncome(logger)
+
+	// collected once per interval
+	exchange.Incomes = append(exchange.Incomes, models.BinanceIncome{Symbol: "LDOUSDT", IncomeType: INCOME_FUNDING, Income: "1", Time: start + 3, TranID: 5})
+	core.CollectIncome(logger)
+
+	incomes := db.GetUserIncomes("user", ID)
+	assert.Len(t, incomes, 3)
+
+	stats := NewStats(core.Setting, db.GetUserTrades("user", ID), incomes)
+
+	assert.Equal(t, 0.4, stats.Funding)
+	assert.Equal(t, 0.1, stats.Fees)
+	assert.Equal(t, 0.3, stats.Net)
+	assert.Equal(t, 20.0, stats.Capital)
+	assert.InDelta(t, 2, stats.Days, 0.01)
+	assert.InDelta(t, 273.75, stats.Apr, 0.1)
+}
@@ -0,0 +1,56 @@
+package modules
+
+import (
+	"sync"
+	"time"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+)
+
+const EXCHANGE_INFO_TTL time.Duration = time.Hour
+
+// ExchangeInfo caches symbol filters and reloads them once they are stale.
+type ExchangeInfo struct {
+	Fetch     func() ([]models.BinanceSymbolInfo, error)
+	Symbols   map[string]models.BinanceSymbolInfo
+	UpdatedAt time.Time
+	mutex     sync.Mutex
+}
+
+func NewExchangeInfo(fetch func() ([]models.BinanceSymbolInfo, error)) *ExchangeInfo {
+	return &ExchangeInfo{
+		Fetch:   fetch,
+		Symbols: make(map[string]models.BinanceSymbolInfo),
+	}
+}
+
+func (e *ExchangeInfo) GetSymbol(symbol string) (*models.BinanceSymbolInfo, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if time.Since(e.UpdatedAt) > EXCHANGE_INFO_TTL {
+		symbols, err := e.Fetch()
+
+		if err != nil && len(e.Symbols) == 0 {
+			return nil, err
+		}
+
+		if err == nil {
+			e.Symbols = make(map[string]models.BinanceSymbolInfo)
+
+			for _, v := range symbols {
+				e.Symbols[v.Symbol] = v
+			}
+
+			e.UpdatedAt = time.Now()
+		}
+	}
+
+	info, ok := e.Symbols[symbol]
+
+	if !ok {
+		return nil, invalidSetting("unknown symbol %s", symbol)
+	}
+
+	return &info, nil
+}
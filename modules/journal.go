@@ -0,0 +1,117 @@
+package modules
+
+import (
+	"time"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	JOURNAL_RETRIES    int           = 3
+	JOURNAL_RETRY_WAIT time.Duration = 200 * time.Millisecond
+)
+
+// TradeStore keeps the trade journal of bots.
+type TradeStore interface {
+	CreateTrade(trade models.Trade) error
+}
+
+// Journal records every accepted order of a batch with its fills.
+func (c *Core) Journal(logger *logrus.Entry, kind string, results []models.BinanceOrderResult) {
+	if c.Trades == nil || c.ID == nil {
+		return
+	}
+
+	batchID := uuid.New().String()
+
+	for _, v := range results {
+		if v.Rejected() {
+			continue
+		}
+
+		// spot returns fills right away, futures have to be asked
+		fills := v.Fills
+		avgPrice, _ := decimal.NewFromString(v.AvgPrice)
+
+		for i := 0; len(fills) == 0 && i < JOURNAL_RETRIES; i++ {
+			if i > 0 {
+				time.Sleep(JOURNAL_RETRY_WAIT)
+			}
+
+			trades, err := c.Exchange.GetOrderTrades(v.Symbol, v.OrderID)
+			if err != nil {
+				c.LogError(logger, err)
+			}
+
+			fills = trades
+
+			// the order response is good enough
+			if avgPrice.IsPositive() {
+				break
+			}
+		}
+
+		if len(fills) == 0 && !avgPrice.IsPositive() {
+			logger.Warn("no fills of order ", v.OrderID, " yet, not journaled")
+			continue
+		}
+
+		var quantity decimal.Decimal
+		var notional decimal.Decimal
+		var fee decimal.Decimal
+		var realizedPnl decimal.Decimal
+
+		trade := models.Trade{
+			ID:        uuid.New().String(),
+			BatchID:   batchID,
+			BotID:     *c.ID,
+			UserID:    c.Setting.UserID,
+			Type:      kind,
+			Symbol:    v.Symbol,
+			OrderID:   v.OrderID,
+			Side:      v.Side,
+			CreatedAt: time.Now().UnixMilli(),
+		}
+
+		for _, f := range fills {
+			qty, _ := decimal.NewFromString(f.Qty)
+			price, _ := decimal.NewFromString(f.Price)
+			commission, _ := decimal.NewFromString(f.Commission)
+			pnl, _ := decimal.NewFromString(f.RealizedPnl)
+
+			quantity = quantity.Add(qty)
+			notional = notional.Add(qty.Mul(price))
+			fee = fee.Add(commission)
+			realizedPnl = realizedPnl.Add(pnl)
+			trade.FeeAsset = f.CommissionAsset
+		}
+
+		// fills aren't available yet, keep what the order response says
+		if quantity.IsZero() {
+			quantity, _ = decimal.NewFromString(v.ExecutedQty)
+
+			if quantity.IsZero() {
+				quantity, _ = decimal.NewFromString(v.OrigQty)
+			}
+
+			notional = quantity.Mul(avgPrice)
+		}
+
+		trade.Quantity, _ = quantity.Float64()
+		trade.Fee, _ = fee.Float64()
+		trade.RealizedPnl, _ = realizedPnl.Float64()
+
+		if !quantity.IsZero() {
+			trade.Price, _ = notional.Div(quantity).Float64()
+		}
+
+		if err := c.Trades.CreateTrade(trade); err != nil {
+			logger.Error(err)
+		}
+
+		c.Publish(models.EventMessage{Type: "fill", Message: trade})
+	}
+}
@@ -0,0 +1,46 @@
+package modules
+
+import (
+	"testing"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJournal(t *testing.T) {
+	db := newTestDB(t)
+	exchange := &fakeExchange{
+		Fills: map[int64][]models.BinanceTrade{
+			1: {
+				{OrderID: 1, Price: "2", Qty: "1", Commission: "0.001", CommissionAsset: "USDT"},
+				{OrderID: 1, Price: "4", Qty: "1", Commission: "0.002", CommissionAsset: "USDT"},
+			},
+		},
+	}
+
+	ID := "bot"
+	core := newTestCore(exchange)
+	core.ID = &ID
+	core.Setting.UserID = "user"
+	core.Trades = db
+
+	core.Journal(logrus.NewEntry(logrus.New()), "place", []models.BinanceOrderResult{
+		{OrderID: 1, Symbol: "LDOUSDT", Side: "SELL", OrigQty: "2"},
+		{OrderID: 2, Symbol: "LDOUSDC", Side: "BUY", OrigQty: "2", ExecutedQty: "2", AvgPrice: "3.1"},
+		// an ACK without fills is never journaled at price 0
+		{OrderID: 3, Symbol: "LDOUSDC", Side: "BUY", OrigQty: "2", ExecutedQty: "0", AvgPrice: "0"},
+		{Code: -2019, Msg: "Margin is insufficient."},
+	})
+
+	trades := db.GetUserTrades("user", ID)
+
+	assert.Len(t, trades, 2)
+	assert.Equal(t, trades[0].BatchID, trades[1].BatchID)
+	assert.Equal(t, 3.0, trades[0].Price)
+	assert.Equal(t, 2.0, trades[0].Quantity)
+	assert.Equal(t, 0.003, trades[0].Fee)
+	assert.Equal(t, 3.1, trades[1].Price)
+	assert.True(t, db.IsUserBot("user", ID))
+	assert.False(t, db.IsUserBot("other", ID))
+}
@@ -0,0 +1,134 @@
+package modules
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+)
+
+// JwtAuth verifies "Authorization: Bearer <jwt>", HS256 signed with a shared secret
+// or RS256 signed for a PEM public key. sub is the user, role "admin" makes an admin.
+type JwtAuth struct {
+	Secret    []byte
+	PublicKey *rsa.PublicKey
+}
+
+// NewJwtAuth verifies HS256 with secret or RS256 with the PEM public key at
+// publicKey, exactly one of them is set.
+func NewJwtAuth(secret string, publicKey string) (*JwtAuth, error) {
+	if (secret == "") == (publicKey == "") {
+		return nil, errors.New("jwt auth needs either -jwtSecret or -jwtPublicKey")
+	}
+
+	if secret != "" {
+		return &JwtAuth{Secret: []byte(secret)}, nil
+	}
+
+	data, err := os.ReadFile(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("jwtPublicKey isn't a PEM file")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("jwtPublicKey isn't a RSA public key")
+	}
+
+	return &JwtAuth{PublicKey: rsaKey}, nil
+}
+
+func (a *JwtAuth) Authenticate(r *http.Request) (*models.User, error) {
+	parts := strings.Split(bearer(r), ".")
+
+	if len(parts) != 3 {
+		return nil, ErrUnauthenticated
+	}
+
+	header := struct {
+		Alg string `json:"alg"`
+	}{}
+
+	if err := decodeJwtPart(parts[0], &header); err != nil {
+		return nil, ErrUnauthenticated
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrUnauthenticated
+	}
+
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	// only the algorithm of the configured key, a token can't pick another one
+	switch {
+	case a.PublicKey != nil && header.Alg == "RS256":
+		if rsa.VerifyPKCS1v15(a.PublicKey, crypto.SHA256, hash[:], signature) != nil {
+			return nil, ErrUnauthenticated
+		}
+	case a.PublicKey == nil && header.Alg == "HS256":
+		mac := hmac.New(sha256.New, a.Secret)
+		mac.Write([]byte(parts[0] + "." + parts[1]))
+
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return nil, ErrUnauthenticated
+		}
+	default:
+		return nil, ErrUnauthenticated
+	}
+
+	claims := struct {
+		Sub  string `json:"sub"`
+		Role string `json:"role"`
+		Exp  int64  `json:"exp"`
+		Nbf  int64  `json:"nbf"`
+	}{}
+
+	if err := decodeJwtPart(parts[1], &claims); err != nil {
+		return nil, ErrUnauthenticated
+	}
+
+	now := time.Now().Unix()
+
+	if claims.Sub == "" || claims.Exp <= now || claims.Nbf > now {
+		return nil, ErrUnauthenticated
+	}
+
+	role := ROLE_USER
+
+	if claims.Role == ROLE_ADMIN {
+		role = ROLE_ADMIN
+	}
+
+	return &models.User{ID: claims.Sub, Role: role}, nil
+}
+
+func decodeJwtPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,266 @@
+package modules
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/ratelimit"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	BINANCE_FAPI_WEIGHT_LIMIT int = 2400
+	BINANCE_FAPI_ORDER_LIMIT  int = 300
+	BINANCE_SPOT_WEIGHT_LIMIT int = 6000
+	BINANCE_SPOT_ORDER_LIMIT  int = 50
+
+	BOT_INTERVAL        time.Duration = 1 * time.Second
+	WEIGHT_WINDOW       time.Duration = 1 * time.Minute
+	ORDER_WINDOW        time.Duration = 10 * time.Second
+	RATE_LIMIT_BACKOFF  time.Duration = 1 * time.Minute
+	RATE_LIMIT_RESPONSE int           = http.StatusTooManyRequests
+	IP_BANNED_RESPONSE  int           = 418
+)
+
+// BINANCE_WEIGHTS is the request weight of each path, 1 when missing.
+var BINANCE_WEIGHTS = map[string]int{
+	BINANCE_FAPI_DEPTH:         2,
+	BINANCE_FAPI_OPEN_ORDERS:   5,
+	BINANCE_FAPI_BATCH_ORDERS:  5,
+	BINANCE_FAPI_BALANCE:       5,
+	BINANCE_FAPI_ACCOUNT:       5,
+	BINANCE_FAPI_USER_TRADES:   5,
+	BINANCE_FAPI_INCOME:        30,
+	BINANCE_FAPI_PREMIUM_INDEX: 10,
+	BINANCE_SPOT_ACCOUNT:       20,
+	BINANCE_SPOT_INFO:          20,
+}
+
+// BINANCE_ORDER_PATHS count against the order limit.
+var BINANCE_ORDER_PATHS = []string{
+	BINANCE_FAPI_BATCH_ORDERS,
+	BINANCE_SPOT_ORDER,
+}
+
+// Limiter paces every bot on its own and keeps one Budget per Binance host,
+// so bots share request weight instead of a single global token.
+// Binance counts orders per account, they have a Budget per API key and host.
+type Limiter struct {
+	Interval time.Duration
+	budgets  map[string]*Budget
+	orders   map[string]*Budget
+	mutex    sync.Mutex
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{
+		Interval: BOT_INTERVAL,
+		budgets:  make(map[string]*Budget),
+		orders:   make(map[string]*Budget),
+	}
+}
+
+// Bot returns the loop pace of a single bot.
+func (l *Limiter) Bot() ratelimit.Limiter {
+	if l == nil {
+		return ratelimit.NewUnlimited()
+	}
+
+	return ratelimit.New(1, ratelimit.Per(l.Interval))
+}
+
+func (l *Limiter) Budget(endpoint string) *Budget {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if budget, ok := l.budgets[endpoint]; ok {
+		return budget
+	}
+
+	budget := NewBudget(BINANCE_FAPI_WEIGHT_LIMIT, 0)
+
+	if endpoint == BINANCE_SPOT_ENDPOINT {
+		budget = NewBudget(BINANCE_SPOT_WEIGHT_LIMIT, 0)
+	}
+
+	l.budgets[endpoint] = budget
+
+	return budget
+}
+
+// Orders returns the order budget of an account on a host, spot and futures
+// have their own order limits. Clients without a key place no orders.
+func (l *Limiter) Orders(endpoint string, apiKey string) *Budget {
+	if l == nil || apiKey == "" {
+		return nil
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	key := endpoint + " " + apiKey
+
+	if budget, ok := l.orders[key]; ok {
+		return budget
+	}
+
+	budget := NewBudget(0, BINANCE_FAPI_ORDER_LIMIT)
+
+	if endpoint == BINANCE_SPOT_ENDPOINT {
+		budget = NewBudget(0, BINANCE_SPOT_ORDER_LIMIT)
+	}
+
+	l.orders[key] = budget
+
+	return budget
+}
+
+// Attach makes b spend the weight budget of its host and the order budget of its key.
+func (l *Limiter) Attach(b *Binance) *Binance {
+	if l != nil {
+		b.Budget = l.Budget(b.Endpoint)
+		b.Limiter = l
+	}
+
+	return b
+}
+
+// Budget lets requests through in arrival order, so no bot starves,
+// holding them back while used weight or order count is at its limit
+// or the host asked us to back off. A limit of 0 isn't enforced.
+type Budget struct {
+	WeightLimit int
+	OrderLimit  int
+	weight      int
+	weightReset time.Time
+	orders      int
+	ordersReset time.Time
+	retryAt     time.Time
+	next        uint64
+	serving     uint64
+	mutex       sync.Mutex
+	cond        *sync.Cond
+}
+
+func NewBudget(weightLimit, orderLimit int) *Budget {
+	b := &Budget{
+		WeightLimit: weightLimit,
+		OrderLimit:  orderLimit,
+	}
+
+	b.cond = sync.NewCond(&b.mutex)
+
+	return b
+}
+
+// Wait blocks until a request to path fits in the budget, then spends it.
+func (b *Budget) Wait(path string) {
+	if b == nil {
+		return
+	}
+
+	weight, ok := BINANCE_WEIGHTS[path]
+
+	if !ok {
+		weight = 1
+	}
+
+	order := slices.Contains(BINANCE_ORDER_PATHS, path)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	ticket := b.next
+	b.next += 1
+
+	for {
+		if ticket != b.serving {
+			b.cond.Wait()
+			continue
+		}
+
+		delay := b.delay(weight, order)
+
+		if delay <= 0 {
+			break
+		}
+
+		b.mutex.Unlock()
+		time.Sleep(delay)
+		b.mutex.Lock()
+	}
+
+	b.weight += weight
+
+	if order {
+		b.orders += 1
+	}
+
+	b.serving += 1
+	b.cond.Broadcast()
+}
+
+func (b *Budget) delay(weight int, order bool) time.Duration {
+	now := time.Now()
+
+	b.reset(now)
+
+	switch {
+	case now.Before(b.retryAt):
+		return b.retryAt.Sub(now)
+	case b.WeightLimit > 0 && b.weight+weight > b.WeightLimit:
+		return b.weightReset.Sub(now)
+	case order && b.OrderLimit > 0 && b.orders+1 > b.OrderLimit:
+		return b.ordersReset.Sub(now)
+	}
+
+	return 0
+}
+
+// reset starts new windows, binance counts per calendar minute & 10 seconds.
+func (b *Budget) reset(now time.Time) {
+	if !now.Before(b.weightReset) {
+		b.weight = 0
+		b.weightReset = now.Truncate(WEIGHT_WINDOW).Add(WEIGHT_WINDOW)
+	}
+
+	if !now.Before(b.ordersReset) {
+		b.orders = 0
+		b.ordersReset = now.Truncate(ORDER_WINDOW).Add(ORDER_WINDOW)
+	}
+}
+
+// Update takes used weight & order count from response headers,
+// they include requests of other processes behind the same IP or account.
+func (b *Budget) Update(header http.Header, status int) {
+	if b == nil {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+
+	b.reset(now)
+
+	if weight, err := strconv.Atoi(header.Get("X-MBX-USED-WEIGHT-1M")); err == nil && weight > b.weight {
+		b.weight = weight
+	}
+
+	if orders, err := strconv.Atoi(header.Get("X-MBX-ORDER-COUNT-10S")); err == nil && orders > b.orders {
+		b.orders = orders
+	}
+
+	if status == RATE_LIMIT_RESPONSE || status == IP_BANNED_RESPONSE {
+		backoff := RATE_LIMIT_BACKOFF
+
+		if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil {
+			backoff = time.Duration(seconds) * time.Second
+		}
+
+		b.retryAt = now.Add(backoff)
+	}
+}
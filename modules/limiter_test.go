@@ -0,0 +1,106 @@
+package modules
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBudgetHeaders(t *testing.T) {
+	status := http.StatusOK
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-MBX-USED-WEIGHT-1M", "2395")
+		w.Header().Set("X-MBX-ORDER-COUNT-10S", "12")
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"bids":[],"asks":[]}`))
+	}))
+	defer server.Close()
+
+	limiter := NewLimiter()
+
+	b := limiter.Attach(newTestBinance(server.URL))
+	b.Budget = limiter.Budget(server.URL)
+	b.Retries = 0
+
+	_, _, _, _, err := b.GetDepth("LDOUSDT")
+	assert.NoError(t, err)
+
+	// used weight of other clients counts too
+	assert.Equal(t, 2395, b.Budget.weight)
+	assert.Equal(t, 12, limiter.Orders(server.URL, "key").orders)
+	assert.Zero(t, b.Budget.delay(BINANCE_WEIGHTS[BINANCE_FAPI_DEPTH], false))
+	assert.Greater(t, b.Budget.delay(BINANCE_WEIGHTS[BINANCE_FAPI_PREMIUM_INDEX], false), time.Duration(0))
+
+	status = RATE_LIMIT_RESPONSE
+
+	_, _, _, _, err = b.GetDepth("LDOUSDT")
+	assert.EqualError(t, err, "binance status 429: Too Many Requests")
+	assert.True(t, IsRetryable(err))
+
+	// nothing is sent until Retry-After passed
+	assert.InDelta(t, time.Second, b.Budget.retryAt.Sub(time.Now()), float64(100*time.Millisecond))
+}
+
+func TestBudgetFair(t *testing.T) {
+	budget := NewBudget(BINANCE_FAPI_WEIGHT_LIMIT, BINANCE_FAPI_ORDER_LIMIT)
+	budget.retryAt = time.Now().Add(200 * time.Millisecond)
+
+	served := make([]int, 0)
+	mutex := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			budget.Wait(BINANCE_FAPI_DEPTH)
+
+			mutex.Lock()
+			served = append(served, i)
+			mutex.Unlock()
+		}(i)
+
+		// let each request take its ticket in order
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, served)
+	assert.Equal(t, 10, budget.weight)
+}
+
+func TestLimiterBudget(t *testing.T) {
+	limiter := NewLimiter()
+
+	assert.Same(t, limiter.Budget(BINANCE_FAPI_ENDPOINT), limiter.Budget(BINANCE_FAPI_ENDPOINT))
+	assert.Equal(t, BINANCE_SPOT_WEIGHT_LIMIT, limiter.Budget(BINANCE_SPOT_ENDPOINT).WeightLimit)
+
+	// orders are counted per account
+	assert.Same(t, limiter.Orders(BINANCE_FAPI_ENDPOINT, "a"), limiter.Orders(BINANCE_FAPI_ENDPOINT, "a"))
+	assert.NotSame(t, limiter.Orders(BINANCE_FAPI_ENDPOINT, "a"), limiter.Orders(BINANCE_FAPI_ENDPOINT, "b"))
+	assert.Equal(t, BINANCE_SPOT_ORDER_LIMIT, limiter.Orders(BINANCE_SPOT_ENDPOINT, "a").OrderLimit)
+	assert.Nil(t, limiter.Orders(BINANCE_FAPI_ENDPOINT, ""))
+
+	// a full order budget of one account doesn't hold back another
+	full := limiter.Orders(BINANCE_FAPI_ENDPOINT, "a")
+	full.reset(time.Now())
+	full.orders = BINANCE_FAPI_ORDER_LIMIT
+
+	assert.Greater(t, full.delay(1, true), time.Duration(0))
+	assert.Zero(t, limiter.Orders(BINANCE_FAPI_ENDPOINT, "b").delay(1, true))
+	assert.Zero(t, limiter.Budget(BINANCE_FAPI_ENDPOINT).delay(1, true))
+
+	// nil limiter leaves clients unlimited
+	var unlimited *Limiter
+
+	assert.Nil(t, unlimited.Attach(NewBinance("", "")).Budget)
+	assert.NotNil(t, unlimited.Bot())
+}
@@ -0,0 +1,172 @@
+package modules
+
+import (
+	"math"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	PROTECT_ACTION_REDUCE string = "reduce"
+	PROTECT_ACTION_MARGIN string = "margin"
+)
+
+// Protect keeps both legs away from liquidation. When a leg is closer than
+// Setting.Protect percent to its liquidation price, isolated margin is moved
+// from the safer leg or both legs are reduced by one order quantity.
+// It returns the quantity reduced from each leg.
+func (c *Core) Protect(logger *logrus.Entry, positions []models.BinanceOrder) float64 {
+	if c.Setting.Protect <= 0 {
+		return 0
+	}
+
+	legs := filter(positions, func(v models.BinanceOrder) bool {
+		amount, _ := decimal.NewFromString(v.PositionAmt)
+
+		return !amount.IsZero() &&
+			(v.Symbol == c.Setting.Symbol+c.Setting.PrimaryQuote ||
+				v.Symbol == c.Setting.Symbol+c.Setting.SecondaryQuote)
+	})
+
+	if len(legs) == 0 {
+		return 0
+	}
+
+	risky := 0
+	distance := math.MaxFloat64
+
+	for i, v := range legs {
+		if d := liquidationDistance(v); d < distance {
+			risky = i
+			distance = d
+		}
+	}
+
+	if distance >= c.Setting.Protect {
+		return 0
+	}
+
+	var marginRatio float64
+
+	account, err := c.Exchange.GetAccount()
+	if err != nil {
+		c.LogError(logger, err)
+	}
+
+	maintMargin, _ := decimal.NewFromString(account.TotalMaintMargin)
+	marginBalance, _ := decimal.NewFromString(account.TotalMarginBalance)
+
+	if !marginBalance.IsZero() {
+		marginRatio, _ = maintMargin.Div(marginBalance).Mul(decimal.NewFromInt(100)).Float64()
+	}
+
+	action := c.Setting.ProtectAction
+
+	if action != PROTECT_ACTION_MARGIN || len(legs) != 2 || legs[0].MarginType != "isolated" || legs[1].MarginType != "isolated" {
+		action = PROTECT_ACTION_REDUCE
+	}
+
+	logger.
+		WithField("distance", distance).
+		WithField("marginRatio", marginRatio).
+		WithField("action", action).
+		Warn(legs[risky].Symbol, " is close to liquidation")
+
+	c.Publish(models.EventMessage{
+		Type: "protect",
+		Message: map[string]any{
+			"symbol":           legs[risky].Symbol,
+			"distance":         distance,
+			"markPrice":        legs[risky].MarkPrice,
+			"liquidationPrice": legs[risky].LiquidationPrice,
+			"marginRatio":      marginRatio,
+			"action":           action,
+		},
+	})
+
+	if action == PROTECT_ACTION_MARGIN {
+		safe := legs[1-risky]
+
+		riskyMargin, _ := decimal.NewFromString(legs[risky].IsolatedMargin)
+		safeMargin, _ := decimal.NewFromString(safe.IsolatedMargin)
+
+		amount, _ := safeMargin.Sub(riskyMargin).Div(decimal.NewFromInt(2)).Float64()
+
+		if amount > 0 {
+			if err := c.Exchange.ModifyMargin(safe.Symbol, amount, false); err != nil {
+				c.LogError(logger, err)
+				return 0
+			}
+
+			if err := c.Exchange.ModifyMargin(legs[risky].Symbol, amount, true); err != nil {
+				c.LogError(logger, err)
+			}
+
+			return 0
+		}
+	}
+
+	// reduce both legs with the smaller of order quantity and open position
+	quantity := decimal.NewFromFloat(c.Setting.Quantity)
+
+	for _, v := range legs {
+		amount, _ := decimal.NewFromString(v.PositionAmt)
+		quantity = decimal.Min(quantity, amount.Abs())
+	}
+
+	orders := make([]models.BinancePlaceOrder, 0)
+
+	for _, v := range legs {
+		amount, _ := decimal.NewFromString(v.PositionAmt)
+
+		order := models.BinancePlaceOrder{
+			Type:       "MARKET",
+			Symbol:     v.Symbol,
+			Side:       "SELL",
+			Quantity:   quantity.String(),
+			ReduceOnly: "true",
+		}
+
+		if amount.IsNegative() {
+			order.Side = "BUY"
+		}
+
+		orders = append(orders, order)
+	}
+
+	results, err := c.Exchange.PlaceBatchOrders(orders)
+	if err != nil {
+		c.LogError(logger, err)
+	}
+
+	c.Journal(logger, "protect", results)
+
+	if c.CheckOrders(logger, results) == 0 {
+		return 0
+	}
+
+	reduced, _ := quantity.Float64()
+
+	return reduced
+}
+
+// liquidationDistance is the gap between mark and liquidation price in percent.
+func liquidationDistance(v models.BinanceOrder) float64 {
+	markPrice, _ := decimal.NewFromString(v.MarkPrice)
+	liquidationPrice, _ := decimal.NewFromString(v.LiquidationPrice)
+
+	if markPrice.IsZero() || liquidationPrice.IsZero() {
+		return math.MaxFloat64
+	}
+
+	distance, _ := markPrice.
+		Sub(liquidationPrice).
+		Div(markPrice).
+		Mul(decimal.NewFromInt(100)).
+		Abs().
+		Float64()
+
+	return distance
+}
@@ -0,0 +1,48 @@
+package modules
+
+import (
+	"testing"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProtectReduce(t *testing.T) {
+	positions := []models.BinanceOrder{
+		{Symbol: "LDOUSDT", PositionAmt: "-5", MarkPrice: "2", LiquidationPrice: "2.1"},
+		{Symbol: "LDOUSDC", PositionAmt: "5", MarkPrice: "2", LiquidationPrice: "1"},
+	}
+	exchange := &fakeExchange{}
+
+	core := newTestCore(exchange)
+	core.Setting.Protect = 10
+	core.Setting.ProtectAction = PROTECT_ACTION_REDUCE
+
+	reduced := core.Protect(logrus.NewEntry(logrus.New()), positions)
+
+	assert.Equal(t, 1.0, reduced)
+	assert.Len(t, exchange.Orders, 2)
+	assert.Equal(t, "BUY", exchange.Orders[0].Side)
+	assert.Equal(t, "SELL", exchange.Orders[1].Side)
+	assert.Equal(t, "true", exchange.Orders[0].ReduceOnly)
+}
+
+func TestProtectMargin(t *testing.T) {
+	positions := []models.BinanceOrder{
+		{Symbol: "LDOUSDT", PositionAmt: "-5", MarkPrice: "2", LiquidationPrice: "2.1", MarginType: "isolated", IsolatedMargin: "1"},
+		{Symbol: "LDOUSDC", PositionAmt: "5", MarkPrice: "2", LiquidationPrice: "1", MarginType: "isolated", IsolatedMargin: "3"},
+	}
+	exchange := &fakeExchange{}
+
+	core := newTestCore(exchange)
+	core.Setting.Protect = 10
+	core.Setting.ProtectAction = PROTECT_ACTION_MARGIN
+
+	reduced := core.Protect(logrus.NewEntry(logrus.New()), positions)
+
+	assert.Equal(t, 0.0, reduced)
+	assert.Empty(t, exchange.Orders)
+	assert.Equal(t, 1.0, exchange.Margins["LDOUSDT"])
+	assert.Equal(t, -1.0, exchange.Margins["LDOUSDC"])
+}
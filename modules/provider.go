@@ -0,0 +1,158 @@
+package modules
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/parnurzeal/gorequest"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
+
+	binance "github.com/CapsLock-Studio/binance-premium-index/models"
+)
+
+// EXTERNAL_QUOTES are the quote currencies the external feed covers.
+var EXTERNAL_QUOTES = []string{"USDT", "BUSD"}
+
+// HedgeProvider supplies funding rate & mark price of every symbol.
+type HedgeProvider interface {
+	GetHedge() ([]binance.BinanceHedge, error)
+}
+
+// ExternalProvider reads hedges published by an external premium index service.
+type ExternalProvider struct {
+	Endpoint string
+}
+
+func NewExternalProvider(endpoint string) *ExternalProvider {
+	return &ExternalProvider{
+		Endpoint: endpoint,
+	}
+}
+
+func (p *ExternalProvider) GetHedge() ([]binance.BinanceHedge, error) {
+	hedge := make([]binance.BinanceHedge, 0)
+
+	_, _, errs := gorequest.
+		New().
+		Get(p.Endpoint).
+		EndStruct(&hedge)
+
+	return hedge, firstError(errs)
+}
+
+// PremiumProvider builds hedges of one quote pair from Binance premium index.
+// Symbols listed with a single quote keep their premium without hedge figures,
+// spot & perp mode only needs that leg.
+type PremiumProvider struct {
+	Fetch     func() ([]binance.BinancePremium, error)
+	Primary   string
+	Secondary string
+}
+
+func NewPremiumProvider(
+	fetch func() ([]binance.BinancePremium, error),
+	primary,
+	secondary string,
+) *PremiumProvider {
+	return &PremiumProvider{
+		Fetch:     fetch,
+		Primary:   primary,
+		Secondary: secondary,
+	}
+}
+
+func (p *PremiumProvider) GetHedge() ([]binance.BinanceHedge, error) {
+	premiums, err := p.Fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	legs := make(map[string][]binance.BinancePremium)
+
+	for _, v := range premiums {
+		for _, quote := range []string{p.Primary, p.Secondary} {
+			if quote != "" && strings.HasSuffix(v.Symbol, quote) {
+				symbol := strings.TrimSuffix(v.Symbol, quote)
+				legs[symbol] = append(legs[symbol], v)
+				break
+			}
+		}
+	}
+
+	hedge := make([]binance.BinanceHedge, 0)
+
+	for symbol, index := range legs {
+		if v := NewHedge(symbol, p.Primary, p.Secondary, index); v != nil {
+			hedge = append(hedge, *v)
+			continue
+		}
+
+		hedge = append(hedge, binance.BinanceHedge{
+			Symbol: symbol,
+			Index:  index,
+		})
+	}
+
+	sort.Slice(hedge, func(i, j int) bool {
+		return hedge[i].Symbol < hedge[j].Symbol
+	})
+
+	return hedge, nil
+}
+
+// FallbackProvider asks providers in order until one returns hedges.
+type FallbackProvider struct {
+	Providers []HedgeProvider
+}
+
+func NewFallbackProvider(providers ...HedgeProvider) *FallbackProvider {
+	return &FallbackProvider{
+		Providers: providers,
+	}
+}
+
+func (p *FallbackProvider) GetHedge() ([]binance.BinanceHedge, error) {
+	var err error
+
+	for _, provider := range p.Providers {
+		var hedge []binance.BinanceHedge
+
+		hedge, err = provider.GetHedge()
+
+		if err == nil && len(hedge) > 0 {
+			return hedge, nil
+		}
+	}
+
+	return nil, err
+}
+
+// NewHedgeProvider reads Binance premium index for the setting's quote pair,
+// falling back to the external feed when setting.FundingFeed is set and
+// the feed covers both quotes.
+func NewHedgeProvider(
+	fetch func() ([]binance.BinancePremium, error),
+	setting *models.ConfigSetting,
+) HedgeProvider {
+	provider := NewPremiumProvider(fetch, setting.PrimaryQuote, setting.SecondaryQuote)
+
+	if setting.FundingFeed == "" {
+		return provider
+	}
+
+	if !slices.Contains(EXTERNAL_QUOTES, setting.PrimaryQuote) ||
+		!slices.Contains(EXTERNAL_QUOTES, setting.SecondaryQuote) {
+		logrus.Warnf(
+			"funding feed only covers %s, no fallback for %s/%s",
+			strings.Join(EXTERNAL_QUOTES, "/"),
+			setting.PrimaryQuote,
+			setting.SecondaryQuote,
+		)
+
+		return provider
+	}
+
+	return NewFallbackProvider(provider, NewExternalProvider(setting.FundingFeed))
+}
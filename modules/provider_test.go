@@ -0,0 +1,79 @@
+package modules
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/stretchr/testify/assert"
+
+	binance "github.com/CapsLock-Studio/binance-premium-index/models"
+)
+
+func TestPremiumProvider(t *testing.T) {
+	provider := NewPremiumProvider(func() ([]binance.BinancePremium, error) {
+		return []binance.BinancePremium{
+			{Symbol: "LDOUSDT", MarkPrice: "2", LastFundingRate: "0.0003"},
+			{Symbol: "LDOUSDC", MarkPrice: "2.02", LastFundingRate: "0.0001"},
+			{Symbol: "BTCUSDT", MarkPrice: "30000", LastFundingRate: "0.0001"},
+			{Symbol: "ETHBUSD", MarkPrice: "2000", LastFundingRate: "0.0001"},
+		}, nil
+	}, "USDT", "USDC")
+
+	hedge, err := provider.GetHedge()
+
+	assert.NoError(t, err)
+	assert.Len(t, hedge, 2)
+
+	// single leg keeps its premium for spot & perp mode
+	assert.Equal(t, "BTC", hedge[0].Symbol)
+	assert.Len(t, hedge[0].Index, 1)
+	assert.Zero(t, hedge[0].FundingRateGap)
+
+	assert.Equal(t, "LDO", hedge[1].Symbol)
+	assert.True(t, hedge[1].Direction)
+	assert.InDelta(t, 0.02, hedge[1].FundingRateGap, 1e-9)
+	assert.InDelta(t, 1, hedge[1].MarkPriceGap, 1e-9)
+}
+
+func TestFallbackProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"symbol":"LDO","fundingRateGap":0.01,"direction":true}]`))
+	}))
+	defer server.Close()
+
+	premium := NewPremiumProvider(func() ([]binance.BinancePremium, error) {
+		return nil, errors.New("premium index is down")
+	}, "USDT", "USDC")
+
+	hedge, err := NewFallbackProvider(premium, NewExternalProvider(server.URL)).GetHedge()
+
+	assert.NoError(t, err)
+	assert.Len(t, hedge, 1)
+	assert.Equal(t, "LDO", hedge[0].Symbol)
+
+	_, err = NewFallbackProvider(premium).GetHedge()
+
+	assert.EqualError(t, err, "premium index is down")
+}
+
+func TestNewHedgeProvider(t *testing.T) {
+	fetch := func() ([]binance.BinancePremium, error) {
+		return nil, errors.New("premium index is down")
+	}
+
+	setting := &models.ConfigSetting{}
+	setting.FundingFeed = "http://127.0.0.1"
+	setting.PrimaryQuote = DEFAULT_PRIMARY_QUOTE
+	setting.SecondaryQuote = DEFAULT_SECONDARY_QUOTE
+
+	// the feed has no USDC pairs, the default quote pair skips it
+	assert.IsType(t, &PremiumProvider{}, NewHedgeProvider(fetch, setting))
+
+	setting.SecondaryQuote = "BUSD"
+	provider := NewHedgeProvider(fetch, setting)
+
+	assert.IsType(t, &FallbackProvider{}, provider)
+}
@@ -0,0 +1,76 @@
+package modules
+
+import "sync"
+
+// Registry keeps running bots by ID, so stop, pause and resume reach the bot directly.
+type Registry struct {
+	bots  map[string]*Core
+	mutex sync.RWMutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		bots: make(map[string]*Core),
+	}
+}
+
+func (r *Registry) Add(ID string, core *Core) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.bots[ID] = core
+}
+
+// Remove forgets the bot unless ID was taken over by another one.
+func (r *Registry) Remove(ID string, core *Core) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.bots[ID] == core {
+		delete(r.bots, ID)
+	}
+}
+
+func (r *Registry) Get(ID string) *Core {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.bots[ID]
+}
+
+// Stop closes the bot, it reports whether the bot was running.
+func (r *Registry) Stop(ID string) bool {
+	core := r.Get(ID)
+
+	if core == nil {
+		return false
+	}
+
+	core.Close()
+
+	return true
+}
+
+func (r *Registry) Pause(ID string) bool {
+	core := r.Get(ID)
+
+	if core == nil {
+		return false
+	}
+
+	core.Pause()
+
+	return true
+}
+
+func (r *Registry) Resume(ID string) bool {
+	core := r.Get(ID)
+
+	if core == nil {
+		return false
+	}
+
+	core.Resume()
+
+	return true
+}
@@ -0,0 +1,43 @@
+package modules
+
+import (
+	"testing"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/ratelimit"
+)
+
+func TestRegistry(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+	registry := NewRegistry()
+
+	setting := &models.ConfigSetting{Symbol: "LDO"}
+
+	core := NewCore(setting, &fakeExchange{}, nil, ratelimit.NewUnlimited())
+	registry.Add("bot", core)
+
+	assert.False(t, registry.Pause("other"))
+	assert.True(t, registry.Pause("bot"))
+	assert.True(t, core.Paused())
+
+	assert.True(t, registry.Resume("bot"))
+	assert.False(t, core.Paused())
+
+	assert.False(t, core.ReceiveClose(logger))
+	assert.True(t, registry.Stop("bot"))
+	assert.True(t, core.ReceiveClose(logger))
+
+	// a restarted bot with the same ID isn't removed by the old one
+	restarted := NewCore(setting, &fakeExchange{}, nil, ratelimit.NewUnlimited())
+	registry.Add("bot", restarted)
+	registry.Remove("bot", core)
+
+	assert.Same(t, restarted, registry.Get("bot"))
+
+	registry.Remove("bot", restarted)
+
+	assert.Nil(t, registry.Get("bot"))
+	assert.False(t, registry.Stop("bot"))
+}
@@ -0,0 +1,133 @@
+package modules
+
+import (
+	"time"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+// CheckOrders logs every order result, publishes a reject event for each
+// rejected order and returns how many orders were accepted.
+func (c *Core) CheckOrders(logger *logrus.Entry, results []models.BinanceOrderResult) int {
+	accepted := 0
+
+	for _, v := range results {
+		if v.Rejected() {
+			logger.
+				WithField("code", v.Code).
+				WithField("msg", v.Msg).
+				Error("order rejected")
+
+			c.Publish(models.EventMessage{
+				Type: "reject",
+				Message: map[string]any{
+					"code": v.Code,
+					"msg":  v.Msg,
+				},
+			})
+
+			continue
+		}
+
+		logger.
+			WithField("orderId", v.OrderID).
+			WithField("status", v.Status).
+			WithField("executedQty", v.ExecutedQty).
+			Info(v.Side, " ", v.Symbol)
+
+		accepted += 1
+	}
+
+	if accepted > 0 {
+		c.lastOrder = time.Now()
+	}
+
+	return accepted
+}
+
+// Repair evens out the two legs after one side of a batch didn't fill.
+// While closing the larger leg is reduced, otherwise the smaller leg is topped up,
+// so either way the interrupted batch gets completed.
+func (c *Core) Repair(logger *logrus.Entry, positions []models.BinanceOrder, closing bool) {
+	primarySymbol := c.Setting.Symbol + c.Setting.PrimaryQuote
+	secondarySymbol := c.Setting.Symbol + c.Setting.SecondaryQuote
+
+	var primaryAmt decimal.Decimal
+	var secondaryAmt decimal.Decimal
+
+	for _, v := range positions {
+		switch v.Symbol {
+		case primarySymbol:
+			primaryAmt, _ = decimal.NewFromString(v.PositionAmt)
+		case secondarySymbol:
+			secondaryAmt, _ = decimal.NewFromString(v.PositionAmt)
+		}
+	}
+
+	// both legs are on the same side, that's not ours to fix
+	if primaryAmt.Sign()*secondaryAmt.Sign() > 0 {
+		logger.Warn("both legs are on the same side")
+		return
+	}
+
+	// legs are opposite, so their sum is the imbalance
+	diff := primaryAmt.Add(secondaryAmt)
+
+	if diff.IsZero() {
+		return
+	}
+
+	larger, smaller := primarySymbol, secondarySymbol
+
+	if secondaryAmt.Abs().GreaterThan(primaryAmt.Abs()) {
+		larger, smaller = secondarySymbol, primarySymbol
+	}
+
+	imbalance, _ := diff.Abs().Float64()
+	quantity := c.RoundQuantity(imbalance)
+
+	if quantity <= 0 {
+		return
+	}
+
+	order := models.BinancePlaceOrder{
+		Type:     "MARKET",
+		Symbol:   smaller,
+		Side:     "SELL",
+		Quantity: decimal.NewFromFloat(quantity).String(),
+	}
+
+	if diff.IsNegative() {
+		order.Side = "BUY"
+	}
+
+	if closing {
+		order.Symbol = larger
+		order.ReduceOnly = "true"
+	}
+
+	logger.
+		WithField(primarySymbol, primaryAmt.String()).
+		WithField(secondarySymbol, secondaryAmt.String()).
+		Warn("legs are imbalanced, ", order.Side, " ", order.Quantity, " ", order.Symbol)
+
+	c.Publish(models.EventMessage{
+		Type: "repair",
+		Message: map[string]any{
+			"symbol":     order.Symbol,
+			"side":       order.Side,
+			"quantity":   order.Quantity,
+			"reduceOnly": closing,
+		},
+	})
+
+	results, err := c.Exchange.PlaceBatchOrders([]models.BinancePlaceOrder{order})
+	if err != nil {
+		c.LogError(logger, err)
+	}
+
+	c.CheckOrders(logger, results)
+	c.Journal(logger, "repair", results)
+}
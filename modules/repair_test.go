@@ -0,0 +1,42 @@
+package modules
+
+import (
+	"testing"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepair(t *testing.T) {
+	positions := []models.BinanceOrder{
+		{Symbol: "LDOUSDT", PositionAmt: "-5"},
+		{Symbol: "LDOUSDC", PositionAmt: "4"},
+	}
+	logger := logrus.NewEntry(logrus.New())
+
+	exchange := &fakeExchange{}
+	core := newTestCore(exchange)
+
+	core.Repair(logger, positions, false)
+
+	assert.Equal(t, []models.BinancePlaceOrder{{Type: "MARKET", Symbol: "LDOUSDC", Side: "BUY", Quantity: "1"}}, exchange.Orders)
+
+	exchange = &fakeExchange{}
+	core.Exchange = exchange
+	core.Repair(logger, positions, true)
+
+	assert.Equal(t, []models.BinancePlaceOrder{{Type: "MARKET", Symbol: "LDOUSDT", Side: "BUY", Quantity: "1", ReduceOnly: "true"}}, exchange.Orders)
+}
+
+func TestCheckOrders(t *testing.T) {
+	exchange := &fakeExchange{Rejects: map[string]bool{"LDOUSDC": true}}
+	core := newTestCore(exchange)
+
+	results, _ := exchange.PlaceBatchOrders([]models.BinancePlaceOrder{
+		{Type: "MARKET", Symbol: "LDOUSDT", Side: "SELL", Quantity: "1"},
+		{Type: "MARKET", Symbol: "LDOUSDC", Side: "BUY", Quantity: "1"},
+	})
+
+	assert.Equal(t, 1, core.CheckOrders(logrus.NewEntry(logrus.New()), results))
+}
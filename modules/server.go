@@ -1,72 +1,214 @@
 package modules
 
 import (
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/CapsLock-Studio/binance-premium-bot/models"
 	"github.com/gin-gonic/gin"
-	"go.uber.org/ratelimit"
+)
+
+const (
+	EVENTS_KEEPALIVE        time.Duration = 15 * time.Second
+	SERVER_SHUTDOWN_TIMEOUT time.Duration = 10 * time.Second
+	DEFAULT_ADDR            string        = ":8080"
 )
 
 type Http struct {
-	Store       string
-	DB          *DB
-	Channel     chan string
-	RateLimiter ratelimit.Limiter
+	Addr      string
+	Store     string
+	DB        *DB
+	Registry  *Registry
+	Broker    *Broker
+	Limiter   *Limiter
+	Hub       *Hub
+	Auth      Authenticator
+	Keepalive time.Duration
+	// CoreFactory builds the bots, the hub is nil for cores that only validate a setting
+	CoreFactory func(setting *models.ConfigSetting, ID *string, hub *Hub) *Core
+	bots        sync.WaitGroup
+	botsMutex   sync.Mutex
 }
 
-func NewHttp(db *DB, ratelimiter ratelimit.Limiter) *Http {
+func NewHttp(db *DB, limiter *Limiter, auth Authenticator) *Http {
+	addr := DEFAULT_ADDR
+
+	if port := os.Getenv("PORT"); port != "" {
+		addr = ":" + port
+	}
+
 	return &Http{
-		Channel:     make(chan string, 10),
-		DB:          db,
-		RateLimiter: ratelimiter,
+		Addr:      addr,
+		DB:        db,
+		Auth:      auth,
+		Registry:  NewRegistry(),
+		Broker:    NewBroker(),
+		Limiter:   limiter,
+		Hub:       NewHub(limiter.Attach(NewBinance("", ""))),
+		Keepalive: EVENTS_KEEPALIVE,
+		CoreFactory: func(setting *models.ConfigSetting, ID *string, hub *Hub) *Core {
+			return NewBinanceCore(setting, ID, limiter, hub)
+		},
 	}
 }
 
-func (h *Http) Serve() {
-	route := gin.Default()
+// Serve runs the API until ctx is canceled, then waits for every bot to stop.
+func (h *Http) Serve(ctx context.Context) {
+	go h.Hub.Run()
 
 	for _, v := range h.DB.GetSates() {
 		var setting models.ConfigSetting
+
+		setting.PrimaryQuote = DEFAULT_PRIMARY_QUOTE
+		setting.SecondaryQuote = DEFAULT_SECONDARY_QUOTE
+
 		if err := json.Unmarshal([]byte(v.Value), &setting); err != nil {
 			log.Fatal(err)
 		}
 
 		setting.UserID = v.UserID
 
-		go h.Bot(setting, v.ID)
+		if err := h.Resolve(&setting); err != nil {
+			log.Println(v.ID, err)
+			continue
+		}
+
+		h.Start(ctx, setting, v.ID)
+	}
+
+	listener, err := net.Listen("tcp", h.Addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	server := &http.Server{
+		Handler: h.Router(ctx),
+		// event streams end with the server
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+	}
+
+	go server.Serve(listener)
+
+	<-ctx.Done()
+
+	// requests in flight may still start bots, let them finish first
+	timeout, cancel := context.WithTimeout(context.Background(), SERVER_SHUTDOWN_TIMEOUT)
+	defer cancel()
+
+	if err := server.Shutdown(timeout); err != nil {
+		log.Println(err)
 	}
 
+	h.botsMutex.Lock()
+	h.botsMutex.Unlock()
+
+	h.bots.Wait()
+}
+
+// Router sets up the API, bots it creates stop with shutdown.
+func (h *Http) Router(shutdown context.Context) *gin.Engine {
+	route := gin.Default()
+
 	route.Use(func(ctx *gin.Context) {
-		userID := ctx.GetHeader("X-USER")
+		user, err := h.Auth.Authenticate(ctx.Request)
 
-		if userID == "" {
-			ctx.AbortWithStatus(http.StatusForbidden)
+		if err != nil {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
 
 		ID := ctx.Param("id")
 
-		if ID != "" {
-			forbidden := true
-			states := h.DB.GetUserSates(userID)
+		if ID != "" && !h.DB.IsUserBot(user.ID, ID) {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
 
-			for _, v := range states {
-				if v.ID == ID {
-					forbidden = false
-				}
-			}
+		ctx.Set("user_id", user.ID)
+		ctx.Set("role", user.Role)
+	})
 
-			if forbidden {
-				ctx.AbortWithStatus(http.StatusForbidden)
-				return
-			}
+	route.GET("/users", h.Admin, func(ctx *gin.Context) {
+		users := h.DB.GetUsers()
+
+		if users == nil {
+			users = make([]string, 0)
 		}
 
-		ctx.Set("user_id", userID)
+		ctx.JSON(http.StatusOK, users)
+	})
+
+	route.DELETE("/users/:userId", h.Admin, func(ctx *gin.Context) {
+		userID := ctx.Param("userId")
+
+		if err := h.DB.DropUserTokens(userID); err != nil {
+			ctx.Data(http.StatusInternalServerError, "text/plain", []byte(err.Error()))
+			return
+		}
+
+		for _, v := range h.DB.GetUserSates(userID) {
+			h.Registry.Stop(v.ID)
+		}
+
+		if err := h.DB.DropUserStates(userID); err != nil {
+			ctx.Data(http.StatusInternalServerError, "text/plain", []byte(err.Error()))
+			return
+		}
+
+		if err := h.DB.DropUserCredentials(userID); err != nil {
+			ctx.Data(http.StatusInternalServerError, "text/plain", []byte(err.Error()))
+			return
+		}
+
+		ctx.Data(http.StatusOK, "text/plain", []byte("DONE"))
+	})
+
+	route.GET("/users/:userId/tokens", h.Admin, func(ctx *gin.Context) {
+		tokens := h.DB.GetUserTokens(ctx.Param("userId"))
+
+		if tokens == nil {
+			tokens = make([]models.Token, 0)
+		}
+
+		ctx.JSON(http.StatusOK, tokens)
+	})
+
+	route.POST("/users/:userId/tokens", h.Admin, func(ctx *gin.Context) {
+		role := ROLE_USER
+
+		if ctx.Query("role") == ROLE_ADMIN {
+			role = ROLE_ADMIN
+		}
+
+		token, err := h.DB.CreateToken(ctx.Param("userId"), role)
+		if err != nil {
+			ctx.Data(http.StatusInternalServerError, "text/plain", []byte(err.Error()))
+			return
+		}
+
+		// the only time the secret is shown
+		ctx.Data(http.StatusOK, "text/plain", []byte(token))
+	})
+
+	route.DELETE("/users/:userId/tokens/:tokenId", h.Admin, func(ctx *gin.Context) {
+		if err := h.DB.DropUserToken(ctx.Param("userId"), ctx.Param("tokenId")); err != nil {
+			ctx.Data(http.StatusInternalServerError, "text/plain", []byte(err.Error()))
+			return
+		}
+
+		ctx.Data(http.StatusOK, "text/plain", []byte("DONE"))
 	})
 
 	route.GET("/", func(ctx *gin.Context) {
@@ -82,11 +224,21 @@ func (h *Http) Serve() {
 	})
 
 	route.POST("/", func(ctx *gin.Context) {
+		if shutdown.Err() != nil {
+			ctx.Data(http.StatusServiceUnavailable, "text/plain", []byte("shutting down"))
+			return
+		}
+
 		var r models.ConfigSetting
 
 		r.Difference = DEFAULT_DIFFERENCE
 		r.Leverage = DEFAULT_LEVERAGE
 		r.Before = DEFAULT_MINUTES
+		r.PrimaryQuote = DEFAULT_PRIMARY_QUOTE
+		r.SecondaryQuote = DEFAULT_SECONDARY_QUOTE
+		r.ProtectAction = PROTECT_ACTION_REDUCE
+		r.RecvWindow = DEFAULT_RECV_WINDOW
+		r.Shutdown = SHUTDOWN_KEEP
 
 		if ctx.Bind(&r) != nil {
 			return
@@ -97,9 +249,41 @@ func (h *Http) Serve() {
 		// set user id
 		r.UserID = userID
 
-		ID := h.DB.CreateUserState(userID, r)
+		if r.CredentialID != "" && (r.ApiKey != "" || r.ApiSecret != "") {
+			ctx.Data(http.StatusBadRequest, "text/plain", []byte("use either credentialId or apiKey and apiSecret"))
+			return
+		}
+
+		if err := ValidateSetting(&r); err != nil {
+			ctx.Data(http.StatusBadRequest, "text/plain", []byte(err.Error()))
+			return
+		}
 
-		go h.Bot(r, ID)
+		if err := h.Resolve(&r); err != nil {
+			ctx.Data(http.StatusBadRequest, "text/plain", []byte(err.Error()))
+			return
+		}
+
+		if err := h.CoreFactory(&r, nil, nil).Validate(); err != nil {
+			ctx.Data(http.StatusBadRequest, "text/plain", []byte(err.Error()))
+			return
+		}
+
+		state := r
+
+		// keys stay in the credential only
+		if state.CredentialID != "" {
+			state.ApiKey = ""
+			state.ApiSecret = ""
+		}
+
+		ID := h.DB.CreateUserState(userID, state)
+
+		// kept, it starts with the server next time
+		if !h.Start(shutdown, r, ID) {
+			ctx.Data(http.StatusServiceUnavailable, "text/plain", []byte("shutting down"))
+			return
+		}
 
 		// response
 		ctx.Data(http.StatusOK, "text/plain", []byte(ID))
@@ -108,15 +292,239 @@ func (h *Http) Serve() {
 	route.DELETE("/:id", func(ctx *gin.Context) {
 		ID := ctx.Param("id")
 
-		h.Channel <- ID
+		h.Registry.Stop(ID)
 
 		h.DB.DropUserState(ctx.GetString("user_id"), ID)
 
 		ctx.Data(http.StatusOK, "text/plain", []byte("DONE"))
 	})
 
+	route.GET("/credentials", func(ctx *gin.Context) {
+		result := make([]models.Credential, 0)
+
+		for _, v := range h.DB.GetUserCredentials(ctx.GetString("user_id")) {
+			result = append(result, v.Masked())
+		}
+
+		ctx.JSON(http.StatusOK, result)
+	})
+
+	route.POST("/credentials", func(ctx *gin.Context) {
+		var r models.Credential
+
+		if ctx.Bind(&r) != nil {
+			return
+		}
+
+		if r.ApiKey == "" || r.ApiSecret == "" {
+			ctx.Data(http.StatusBadRequest, "text/plain", []byte("apiKey and apiSecret are required"))
+			return
+		}
+
+		ID, err := h.DB.CreateCredential(ctx.GetString("user_id"), r)
+		if err != nil {
+			ctx.Data(http.StatusInternalServerError, "text/plain", []byte(err.Error()))
+			return
+		}
+
+		ctx.Data(http.StatusOK, "text/plain", []byte(ID))
+	})
+
+	route.PUT("/credentials/:credentialId", func(ctx *gin.Context) {
+		userID := ctx.GetString("user_id")
+		ID := ctx.Param("credentialId")
+
+		var r models.Credential
+
+		if ctx.Bind(&r) != nil {
+			return
+		}
+
+		if r.ApiKey == "" || r.ApiSecret == "" {
+			ctx.Data(http.StatusBadRequest, "text/plain", []byte("apiKey and apiSecret are required"))
+			return
+		}
+
+		credential, err := h.DB.GetUserCredential(userID, ID)
+		if err != nil {
+			ctx.Data(http.StatusInternalServerError, "text/plain", []byte(err.Error()))
+			return
+		}
+
+		if credential == nil {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		if err := h.DB.RotateUserCredential(userID, ID, r.ApiKey, r.ApiSecret); err != nil {
+			ctx.Data(http.StatusInternalServerError, "text/plain", []byte(err.Error()))
+			return
+		}
+
+		for _, botID := range h.GetCredentialBots(userID, ID) {
+			if core := h.Registry.Get(botID); core != nil {
+				core.Rotate(r.ApiKey, r.ApiSecret)
+			}
+		}
+
+		ctx.Data(http.StatusOK, "text/plain", []byte("DONE"))
+	})
+
+	route.DELETE("/credentials/:credentialId", func(ctx *gin.Context) {
+		userID := ctx.GetString("user_id")
+		ID := ctx.Param("credentialId")
+
+		if bots := h.GetCredentialBots(userID, ID); len(bots) > 0 {
+			ctx.Data(http.StatusConflict, "text/plain", []byte("credential is used by "+strings.Join(bots, ", ")))
+			return
+		}
+
+		if err := h.DB.DropUserCredential(userID, ID); err != nil {
+			ctx.Data(http.StatusInternalServerError, "text/plain", []byte(err.Error()))
+			return
+		}
+
+		ctx.Data(http.StatusOK, "text/plain", []byte("DONE"))
+	})
+
+	route.GET("/events", func(ctx *gin.Context) {
+		h.Events(ctx, "")
+	})
+
+	route.GET("/:id/events", func(ctx *gin.Context) {
+		h.Events(ctx, ctx.Param("id"))
+	})
+
+	route.GET("/:id", func(ctx *gin.Context) {
+		core := h.Registry.Get(ctx.Param("id"))
+
+		if core == nil {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, core.Status())
+	})
+
+	route.PATCH("/:id", func(ctx *gin.Context) {
+		userID := ctx.GetString("user_id")
+		ID := ctx.Param("id")
+
+		var patch models.SettingPatch
+
+		decoder := json.NewDecoder(ctx.Request.Body)
+		decoder.DisallowUnknownFields()
+
+		if err := decoder.Decode(&patch); err != nil {
+			ctx.Data(http.StatusBadRequest, "text/plain", []byte(err.Error()))
+			return
+		}
+
+		core := h.Registry.Get(ID)
+		setting := h.GetSetting(userID, ID)
+
+		if core == nil || setting == nil {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		// the loop changes its setting, check the patch against what it runs
+		running := core.RunningSetting()
+
+		if (running.Arbitrage || setting.Arbitrage) && (patch.Difference != nil || patch.Total != nil) {
+			ctx.Data(http.StatusBadRequest, "text/plain", []byte("arbitrage mode sets difference and total itself"))
+			return
+		}
+
+		ApplyPatch(&running, patch)
+		patch.Apply(setting)
+
+		if err := ValidateSetting(&running); err != nil {
+			ctx.Data(http.StatusBadRequest, "text/plain", []byte(err.Error()))
+			return
+		}
+
+		if err := h.CoreFactory(&running, nil, nil).Validate(); err != nil {
+			ctx.Data(http.StatusBadRequest, "text/plain", []byte(err.Error()))
+			return
+		}
+
+		if err := h.DB.UpdateUserState(userID, ID, setting); err != nil {
+			ctx.Data(http.StatusInternalServerError, "text/plain", []byte(err.Error()))
+			return
+		}
+
+		core.Update(patch)
+
+		ctx.Data(http.StatusOK, "text/plain", []byte("DONE"))
+	})
+
+	route.POST("/:id/pause", func(ctx *gin.Context) {
+		h.SetPaused(ctx, true)
+	})
+
+	route.POST("/:id/resume", func(ctx *gin.Context) {
+		h.SetPaused(ctx, false)
+	})
+
+	route.GET("/:id/trades", func(ctx *gin.Context) {
+		trades := h.DB.GetUserTrades(ctx.GetString("user_id"), ctx.Param("id"))
+
+		if trades == nil {
+			trades = make([]models.Trade, 0)
+		}
+
+		if ctx.Query("format") != "csv" {
+			ctx.JSON(http.StatusOK, trades)
+			return
+		}
+
+		ctx.Header("Content-Type", "text/csv")
+		ctx.Header("Content-Disposition", "attachment; filename="+ctx.Param("id")+".csv")
+		ctx.Status(http.StatusOK)
+
+		w := csv.NewWriter(ctx.Writer)
+		w.Write([]string{"id", "batchId", "botId", "userId", "type", "symbol", "orderId", "side", "quantity", "price", "fee", "feeAsset", "realizedPnl", "createdAt"})
+
+		for _, v := range trades {
+			w.Write([]string{
+				v.ID,
+				v.BatchID,
+				v.BotID,
+				v.UserID,
+				v.Type,
+				v.Symbol,
+				fmt.Sprint(v.OrderID),
+				v.Side,
+				fmt.Sprint(v.Quantity),
+				fmt.Sprint(v.Price),
+				fmt.Sprint(v.Fee),
+				v.FeeAsset,
+				fmt.Sprint(v.RealizedPnl),
+				time.UnixMilli(v.CreatedAt).UTC().Format(time.RFC3339),
+			})
+		}
+
+		w.Flush()
+	})
+
+	route.GET("/:id/stats", func(ctx *gin.Context) {
+		userID := ctx.GetString("user_id")
+		ID := ctx.Param("id")
+
+		setting := h.GetSetting(userID, ID)
+
+		ctx.JSON(http.StatusOK, NewStats(setting, h.DB.GetUserTrades(userID, ID), h.DB.GetUserIncomes(userID, ID)))
+	})
+
 	route.DELETE("/", func(ctx *gin.Context) {
-		err := h.DB.DropUserStates(ctx.GetString("user_id"))
+		userID := ctx.GetString("user_id")
+
+		for _, v := range h.DB.GetUserSates(userID) {
+			h.Registry.Stop(v.ID)
+		}
+
+		err := h.DB.DropUserStates(userID)
 
 		if err != nil {
 			return
@@ -125,10 +533,161 @@ func (h *Http) Serve() {
 		ctx.Data(http.StatusOK, "text/plain", []byte("DONE"))
 	})
 
-	route.Run()
+	return route
+}
+
+// Admin lets only admins through.
+func (h *Http) Admin(ctx *gin.Context) {
+	if ctx.GetString("role") != ROLE_ADMIN {
+		ctx.AbortWithStatus(http.StatusForbidden)
+	}
+}
+
+// GetSetting returns the stored setting of a bot, nil when the user has no such bot.
+func (h *Http) GetSetting(userID, ID string) *models.ConfigSetting {
+	for _, v := range h.DB.GetUserSates(userID) {
+		if v.ID == ID {
+			setting := &models.ConfigSetting{}
+			setting.PrimaryQuote = DEFAULT_PRIMARY_QUOTE
+			setting.SecondaryQuote = DEFAULT_SECONDARY_QUOTE
+
+			json.Unmarshal([]byte(v.Value), setting)
+
+			setting.UserID = userID
+
+			return setting
+		}
+	}
+
+	return nil
+}
+
+// Resolve fills apiKey and apiSecret of a setting that references a credential of its user.
+func (h *Http) Resolve(setting *models.ConfigSetting) error {
+	if setting.CredentialID == "" {
+		return nil
+	}
+
+	credential, err := h.DB.GetUserCredential(setting.UserID, setting.CredentialID)
+	if err != nil {
+		return err
+	}
+
+	if credential == nil {
+		return invalidSetting("credential %s not found", setting.CredentialID)
+	}
+
+	setting.ApiKey = credential.ApiKey
+	setting.ApiSecret = credential.ApiSecret
+
+	return nil
+}
+
+// GetCredentialBots returns the bots of the user that reference the credential.
+func (h *Http) GetCredentialBots(userID, credentialID string) []string {
+	result := make([]string, 0)
+
+	for _, v := range h.DB.GetUserSates(userID) {
+		var setting models.ConfigSetting
+
+		if json.Unmarshal([]byte(v.Value), &setting) == nil && setting.CredentialID == credentialID {
+			result = append(result, v.ID)
+		}
+	}
+
+	return result
+}
+
+// SetPaused pauses or resumes a running bot and keeps it that way after a restart.
+func (h *Http) SetPaused(ctx *gin.Context, paused bool) {
+	userID := ctx.GetString("user_id")
+	ID := ctx.Param("id")
+
+	setting := h.GetSetting(userID, ID)
+
+	if setting == nil || h.Registry.Get(ID) == nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	setting.Paused = paused
+
+	if err := h.DB.UpdateUserState(userID, ID, setting); err != nil {
+		ctx.Data(http.StatusInternalServerError, "text/plain", []byte(err.Error()))
+		return
+	}
+
+	if paused {
+		h.Registry.Pause(ID)
+	} else {
+		h.Registry.Resume(ID)
+	}
+
+	ctx.Data(http.StatusOK, "text/plain", []byte("DONE"))
+}
+
+// Events streams bot events of the user as server-sent events until the client leaves,
+// only events of botID when it's set.
+func (h *Http) Events(ctx *gin.Context, botID string) {
+	subscription := h.Broker.Subscribe(ctx.GetString("user_id"), botID)
+	defer h.Broker.Unsubscribe(subscription)
+
+	keepalive := time.NewTicker(h.Keepalive)
+	defer keepalive.Stop()
+
+	// set before the first write, a keepalive would be sniffed as text/plain
+	ctx.Header("Content-Type", "text/event-stream")
+
+	// don't let proxies buffer the stream
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("X-Accel-Buffering", "no")
+
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case event := <-subscription.Events:
+			ctx.SSEvent(event.Type, event)
+		case <-keepalive.C:
+			w.Write([]byte(": keepalive\n\n"))
+		case <-ctx.Request.Context().Done():
+			return false
+		}
+
+		return true
+	})
+}
+
+// Start registers a bot and runs it in the background, Serve waits for it on shutdown.
+// The bot is registered before Start returns, so it can be stopped right away.
+// It returns false without starting the bot once ctx is canceled.
+func (h *Http) Start(ctx context.Context, setting models.ConfigSetting, ID string) bool {
+	// Serve waits for bots after taking this lock, none is added after that
+	h.botsMutex.Lock()
+	defer h.botsMutex.Unlock()
+
+	if ctx.Err() != nil {
+		return false
+	}
+
+	core := h.CoreFactory(&setting, &ID, h.Hub)
+	core.Store = h.DB
+	core.Trades = h.DB
+	core.Incomes = h.DB
+	core.Broker = h.Broker
+
+	h.Registry.Add(ID, core)
+	h.bots.Add(1)
+
+	go func() {
+		defer h.bots.Done()
+		defer h.Registry.Remove(ID, core)
+
+		h.Bot(ctx, core, ID)
+	}()
+
+	return true
 }
 
-func (h *Http) Bot(setting models.ConfigSetting, ID string) {
+func (h *Http) Bot(ctx context.Context, core *Core, ID string) {
 	defer func() {
 		if err := recover(); err != nil {
 			log.Fatal(err)
@@ -136,7 +695,12 @@ func (h *Http) Bot(setting models.ConfigSetting, ID string) {
 		}
 	}()
 
-	NewCore(&setting, h.Channel, &ID, h.RateLimiter).Run()
+	core.Run(ctx)
+
+	// resume on next start
+	if ctx.Err() != nil {
+		return
+	}
 
 	// drop state
 	h.DB.DropState(ID)
@@ -0,0 +1,296 @@
+package modules
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/ratelimit"
+)
+
+func TestServerShutdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := newTestDB(t)
+	exchange := &fakeExchange{
+		Depth: map[string][4]float64{"LDOUSDT": {2, 100, 2.1, 100}},
+		Infos: []models.BinanceSymbolInfo{newTestInfo("LDOUSDT"), newTestInfo("LDOUSDC")},
+	}
+
+	h := NewHttp(db, NewLimiter(), &ProxyAuth{})
+	h.CoreFactory = func(setting *models.ConfigSetting, ID *string, hub *Hub) *Core {
+		core := NewCore(setting, exchange, ID, ratelimit.NewUnlimited())
+		core.Provider = &fakeProvider{}
+
+		if setting.Paused {
+			core.Pause()
+		}
+
+		return core
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"symbol": "LDO", "quantity": 3, "total": 9, "paused": true}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-USER", "user")
+
+	h.Router(ctx).ServeHTTP(w, r)
+
+	assert.Equal(t, 200, w.Code, w.Body.String())
+	assert.NotNil(t, h.Registry.Get(w.Body.String()))
+
+	cancel()
+
+	stopped := make(chan struct{})
+
+	go func() {
+		h.bots.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("bot created over http didn't stop with the server")
+	}
+
+	// kept for the next start
+	assert.Len(t, db.GetSates(), 1)
+}
+
+func TestServerDeleteAll(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := newTestDB(t)
+	exchange := &fakeExchange{
+		Depth: map[string][4]float64{"LDOUSDT": {2, 100, 2.1, 100}},
+		Infos: []models.BinanceSymbolInfo{newTestInfo("LDOUSDT"), newTestInfo("LDOUSDC")},
+	}
+
+	h := NewHttp(db, NewLimiter(), &ProxyAuth{})
+	h.CoreFactory = func(setting *models.ConfigSetting, ID *string, hub *Hub) *Core {
+		core := NewCore(setting, exchange, ID, ratelimit.NewUnlimited())
+		core.Provider = &fakeProvider{}
+		core.Pause()
+
+		return core
+	}
+
+	router := h.Router(context.Background())
+
+	request := func(method, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("X-USER", "user")
+
+		router.ServeHTTP(w, r)
+
+		return w
+	}
+
+	ID := request("POST", `{"symbol": "LDO", "quantity": 3, "total": 9}`).Body.String()
+	assert.NotNil(t, h.Registry.Get(ID))
+
+	assert.Equal(t, 200, request("DELETE", "").Code)
+
+	stopped := make(chan struct{})
+
+	go func() {
+		h.bots.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("bot kept running after DELETE /")
+	}
+
+	assert.Nil(t, h.Registry.Get(ID))
+	assert.Empty(t, db.GetSates())
+}
+
+func TestServerEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	h := NewHttp(newTestDB(t), NewLimiter(), &ProxyAuth{})
+	h.Keepalive = 10 * time.Millisecond
+
+	server := httptest.NewServer(h.Router(context.Background()))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/events", nil)
+	r.Header.Set("X-USER", "user")
+
+	// a keepalive is written first
+	response, err := http.DefaultClient.Do(r)
+	assert.NoError(t, err)
+	defer response.Body.Close()
+
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(response.Body)
+
+	line, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, ": keepalive\n", line)
+
+	h.Broker.Publish(models.BotEvent{Type: "create", ID: "bot", UserID: "user"})
+
+	for line != "event:create\n" && err == nil {
+		line, err = reader.ReadString('\n')
+	}
+
+	assert.NoError(t, err)
+}
+
+func TestServerValidateFirst(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	h := NewHttp(newTestDB(t), NewLimiter(), &ProxyAuth{})
+	h.CoreFactory = func(setting *models.ConfigSetting, ID *string, hub *Hub) *Core {
+		t.Fatal("an invalid setting reached the exchange")
+		return nil
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"symbol": "LDO", "quantity": 3, "total": 1}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-USER", "user")
+
+	h.Router(context.Background()).ServeHTTP(w, r)
+
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, "total must be greater than or equal to quantity", w.Body.String())
+}
+
+func TestServeShutdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := listener.Addr().String()
+	listener.Close()
+
+	h := NewHttp(newTestDB(t), NewLimiter(), &ProxyAuth{})
+	h.Addr = addr
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan struct{})
+
+	go func() {
+		h.Serve(ctx)
+		close(served)
+	}()
+
+	post := func() *http.Request {
+		r, _ := http.NewRequest("POST", "http://"+addr+"/", strings.NewReader(`{"symbol": "LDO", "quantity": 3, "total": 9}`))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("X-USER", "user")
+
+		return r
+	}
+
+	assert.Eventually(t, func() bool {
+		r, _ := http.NewRequest("GET", "http://"+addr+"/", nil)
+		r.Header.Set("X-USER", "user")
+
+		response, err := http.DefaultClient.Do(r)
+		if err != nil {
+			return false
+		}
+
+		response.Body.Close()
+
+		return response.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve didn't return after shutdown")
+	}
+
+	// the server is closed
+	_, err := http.DefaultClient.Do(post())
+	assert.Error(t, err)
+
+	// and a request that got in before doesn't start a bot
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"symbol": "LDO", "quantity": 3, "total": 9}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-USER", "user")
+
+	h.Router(ctx).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.False(t, h.Start(ctx, models.ConfigSetting{Symbol: "LDO"}, "bot"))
+	assert.Nil(t, h.Registry.Get("bot"))
+}
+
+func TestServerPatchArbitrage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	exchange := &fakeExchange{
+		Depth: map[string][4]float64{"LDOUSDT": {2, 100, 2.1, 100}},
+		Infos: []models.BinanceSymbolInfo{newTestInfo("LDOUSDT"), newTestInfo("LDOUSDC")},
+	}
+
+	h := NewHttp(newTestDB(t), NewLimiter(), &ProxyAuth{})
+	h.CoreFactory = func(setting *models.ConfigSetting, ID *string, hub *Hub) *Core {
+		core := NewCore(setting, exchange, ID, ratelimit.NewUnlimited())
+		core.Provider = &fakeProvider{}
+		core.Pause()
+
+		return core
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer h.bots.Wait()
+	defer cancel()
+
+	router := h.Router(ctx)
+
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("X-USER", "user")
+
+		router.ServeHTTP(w, r)
+
+		return w
+	}
+
+	// arbitrage mode needs no total
+	w := request("POST", "/", `{"symbol": "LDO", "quantity": 3, "arbitrage": true}`)
+	assert.Equal(t, 200, w.Code, w.Body.String())
+
+	ID := w.Body.String()
+
+	w = request("PATCH", "/"+ID, `{"difference": 0.2}`)
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, "arbitrage mode sets difference and total itself", w.Body.String())
+
+	assert.Equal(t, 400, request("PATCH", "/"+ID, `{"total": 10}`).Code)
+	assert.Equal(t, 200, request("PATCH", "/"+ID, `{"quantity": 4}`).Code)
+
+	running := h.Registry.Get(ID).RunningSetting()
+	assert.Equal(t, 4.0, running.Quantity)
+	assert.Equal(t, 4.0, running.Total)
+}
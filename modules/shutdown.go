@@ -0,0 +1,93 @@
+package modules
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	SHUTDOWN_KEEP   string = "keep"
+	SHUTDOWN_UNWIND string = "unwind"
+)
+
+// NotifyShutdown returns a context canceled on SIGINT or SIGTERM,
+// a second signal exits at once without waiting for bots.
+func NotifyShutdown() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		sig := <-signals
+		logrus.Info("receive ", sig, ", stop bots after their current batch...")
+		cancel()
+
+		<-signals
+		logrus.Warn("receive second signal, exit now")
+		os.Exit(1)
+	}()
+
+	return ctx
+}
+
+// ReceiveShutdown reports whether the bot has to stop for a shutdown.
+// With the unwind policy the bot switches to reduce mode instead and stops once its positions are closed.
+func (c *Core) ReceiveShutdown(ctx context.Context, logger *logrus.Entry) bool {
+	if ctx.Err() == nil || c.unwinding {
+		return false
+	}
+
+	c.Publish(models.EventMessage{Type: "shutdown", Message: c.Setting.Shutdown})
+
+	// paused bots don't place orders, not even to unwind
+	if c.Setting.Shutdown != SHUTDOWN_UNWIND || c.Paused() {
+		logger.Info("shutdown, keep positions")
+		return true
+	}
+
+	logger.Info("shutdown, unwind positions...")
+
+	c.unwinding = true
+	c.Unwind(logger)
+
+	return false
+}
+
+// Unwind switches the bot to reduce mode for everything it holds.
+func (c *Core) Unwind(logger *logrus.Entry) {
+	c.Setting.Reduce = true
+	c.Setting.Arbitrage = false
+
+	if c.Setting.Spot {
+		return
+	}
+
+	positions, err := c.Exchange.GetPositionRisk()
+	if err != nil {
+		c.LogError(logger, err)
+	}
+
+	hedged, direction := c.GetOpenHedge(positions)
+
+	c.Setting.Total = hedged
+
+	c.State.TotalQuantity = hedged
+	c.State.Step = 1
+	c.State.FundingRateReverseMode = false
+	c.State.ArbitrageDirection = nil
+	c.State.ArbitrageTriggered = false
+	c.State.ShutdownUnwind = true
+
+	if direction != nil {
+		c.State.CurrentDirection = direction
+	}
+
+	// a restart mustn't resume this reduce mode, even if no batch follows
+	c.Checkpoint(logger)
+}
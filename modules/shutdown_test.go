@@ -0,0 +1,143 @@
+package modules
+
+import (
+	"context"
+	"testing"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReceiveShutdown(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+	ctx, cancel := context.WithCancel(context.Background())
+
+	exchange := &fakeExchange{
+		Positions: []models.BinanceOrder{
+			{Symbol: "LDOUSDT", PositionAmt: "-4"},
+			{Symbol: "LDOUSDC", PositionAmt: "4"},
+		},
+	}
+
+	core := newTestCore(exchange)
+	core.Setting.Arbitrage = true
+	core.State = &models.CoreState{Step: -1, TotalQuantity: 6}
+
+	assert.False(t, core.ReceiveShutdown(ctx, logger))
+
+	cancel()
+
+	// keep positions
+	assert.True(t, core.ReceiveShutdown(ctx, logger))
+	assert.False(t, core.Setting.Reduce)
+
+	// close both legs in reduce mode before stopping
+	core.Setting.Shutdown = SHUTDOWN_UNWIND
+
+	assert.False(t, core.ReceiveShutdown(ctx, logger))
+	assert.True(t, core.Setting.Reduce)
+	assert.False(t, core.Setting.Arbitrage)
+	assert.Equal(t, 4.0, core.Setting.Total)
+	assert.Equal(t, 4.0, core.State.TotalQuantity)
+	assert.Equal(t, 1, core.State.Step)
+	assert.True(t, *core.State.CurrentDirection)
+
+	// unwinding only starts once
+	assert.False(t, core.ReceiveShutdown(ctx, logger))
+}
+
+func TestUnwindRestart(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	db := newTestDB(t)
+	ID := "bot"
+
+	exchange := &fakeExchange{
+		Positions: []models.BinanceOrder{
+			{Symbol: "LDOUSDT", PositionAmt: "-4"},
+			{Symbol: "LDOUSDC", PositionAmt: "4"},
+		},
+	}
+
+	core := newTestCore(exchange)
+	core.ID = &ID
+	core.Setting.Shutdown = SHUTDOWN_UNWIND
+	core.Store = db
+	core.Prepare(logger)
+
+	assert.False(t, core.ReceiveShutdown(ctx, logger))
+	assert.True(t, core.Setting.Reduce)
+
+	// both legs got closed before the process exited
+	exchange.Positions = nil
+
+	restarted := newTestCore(exchange)
+	restarted.ID = &ID
+	restarted.Setting.Shutdown = SHUTDOWN_UNWIND
+	restarted.Store = db
+	restarted.Provider = &fakeProvider{}
+	restarted.Prepare(logger)
+
+	assert.False(t, restarted.Setting.Reduce)
+	assert.Equal(t, 10.0, restarted.Setting.Total)
+	assert.Equal(t, 10.0, restarted.State.TotalQuantity)
+	assert.False(t, restarted.State.ShutdownUnwind)
+	assert.True(t, restarted.Tick(logger))
+}
+
+func TestUnwindRestartPartial(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	db := newTestDB(t)
+	ID := "bot"
+
+	exchange := &fakeExchange{
+		Positions: []models.BinanceOrder{
+			{Symbol: "LDOUSDT", PositionAmt: "-4"},
+			{Symbol: "LDOUSDC", PositionAmt: "4"},
+		},
+	}
+
+	core := newTestCore(exchange)
+	core.ID = &ID
+	core.Setting.Shutdown = SHUTDOWN_UNWIND
+	core.Store = db
+	core.Prepare(logger)
+
+	assert.False(t, core.ReceiveShutdown(ctx, logger))
+
+	// the process exited halfway through the unwind
+	exchange.Positions = []models.BinanceOrder{
+		{Symbol: "LDOUSDT", PositionAmt: "-2"},
+		{Symbol: "LDOUSDC", PositionAmt: "2"},
+	}
+
+	restarted := newTestCore(exchange)
+	restarted.ID = &ID
+	restarted.Setting.Shutdown = SHUTDOWN_UNWIND
+	restarted.Store = db
+	restarted.Provider = &fakeProvider{}
+	restarted.Prepare(logger)
+
+	// it goes on reducing instead of opening again
+	assert.True(t, restarted.Setting.Reduce)
+	assert.Equal(t, 4.0, restarted.Setting.Total)
+	assert.Equal(t, 2.0, restarted.State.TotalQuantity)
+	assert.True(t, *restarted.State.CurrentDirection)
+	assert.True(t, restarted.State.ShutdownUnwind)
+
+	// and starts over once it's done
+	exchange.Positions = nil
+	restarted.State.TotalQuantity = 0
+
+	assert.True(t, restarted.Tick(logger))
+	assert.False(t, restarted.Setting.Reduce)
+	assert.Equal(t, 10.0, restarted.Setting.Total)
+	assert.Equal(t, 10.0, restarted.State.TotalQuantity)
+	assert.False(t, restarted.State.ShutdownUnwind)
+}
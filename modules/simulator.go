@@ -0,0 +1,301 @@
+package modules
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/shopspring/decimal"
+
+	binance "github.com/CapsLock-Studio/binance-premium-index/models"
+)
+
+const (
+	SIMULATED_FEE float64 = 0.0005
+)
+
+// Simulator is an Exchange filling market orders against the fed snapshot.
+// Orders fill at the top level Core saw, fees are charged on notional and
+// funding is settled whenever a snapshot passes the next funding time.
+// With Market and Upstream set it runs on live data for dry-run bots,
+// every hedge fetched through it is fed as a new snapshot.
+type Simulator struct {
+	Fee       float64
+	Market    Exchange
+	Upstream  HedgeProvider
+	Snapshot  models.Snapshot
+	Positions map[string]decimal.Decimal
+	Cash      decimal.Decimal
+	Fees      decimal.Decimal
+	Funding   decimal.Decimal
+	Orders    int
+	Incomes   []models.BinanceIncome
+	premiums  map[string]binance.BinancePremium
+	settled   map[string]int
+	orderID   int64
+	mutex     sync.Mutex
+}
+
+func NewSimulator(fee float64) *Simulator {
+	return &Simulator{
+		Fee:       fee,
+		Positions: make(map[string]decimal.Decimal),
+		premiums:  make(map[string]binance.BinancePremium),
+		settled:   make(map[string]int),
+	}
+}
+
+// Feed moves the simulator to snapshot and settles funding of open positions.
+func (s *Simulator) Feed(snapshot models.Snapshot) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := snapshot.GetTime()
+
+	for symbol, amount := range s.Positions {
+		last, ok := s.premiums[symbol]
+
+		if !ok || amount.IsZero() || last.NextFundingTime <= 0 || now < int64(last.NextFundingTime) {
+			continue
+		}
+
+		// recordings may repeat a funding time until the index refreshes
+		if s.settled[symbol] == last.NextFundingTime {
+			continue
+		}
+
+		s.settled[symbol] = last.NextFundingTime
+
+		rate, _ := decimal.NewFromString(last.LastFundingRate)
+		markPrice, _ := decimal.NewFromString(last.MarkPrice)
+
+		// longs pay shorts when the rate is positive
+		funding := amount.Mul(markPrice).Mul(rate).Neg()
+
+		s.Cash = s.Cash.Add(funding)
+		s.Funding = s.Funding.Add(funding)
+
+		s.Incomes = append(s.Incomes, models.BinanceIncome{
+			Symbol:     symbol,
+			IncomeType: INCOME_FUNDING,
+			Income:     funding.String(),
+			Time:       now,
+			TranID:     int64(len(s.Incomes) + 1),
+		})
+	}
+
+	for _, h := range snapshot.Hedge {
+		for _, i := range h.Index {
+			s.premiums[i.Symbol] = i
+		}
+	}
+
+	s.Snapshot = snapshot
+}
+
+// GetHedge fetches Upstream and feeds it as a snapshot taken now.
+func (s *Simulator) GetHedge() ([]binance.BinanceHedge, error) {
+	if s.Upstream == nil {
+		return s.Snapshot.Hedge, nil
+	}
+
+	hedge, err := s.Upstream.GetHedge()
+	if err != nil {
+		return hedge, err
+	}
+
+	s.Feed(models.Snapshot{Time: time.Now().UnixMilli(), Hedge: hedge})
+
+	return hedge, nil
+}
+
+// Equity is cash plus open positions at mark price, it starts from 0.
+func (s *Simulator) Equity() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	equity := s.Cash
+
+	for symbol, amount := range s.Positions {
+		equity = equity.Add(amount.Mul(s.markPrice(symbol)))
+	}
+
+	value, _ := equity.Float64()
+
+	return value
+}
+
+func (s *Simulator) markPrice(symbol string) decimal.Decimal {
+	markPrice, _ := decimal.NewFromString(s.premiums[symbol].MarkPrice)
+
+	return markPrice
+}
+
+// GetDepth uses live or recorded depth, or mark price with unlimited size when none was recorded.
+func (s *Simulator) GetDepth(
+	symbol string,
+) (
+	bid,
+	bidSize,
+	ask,
+	askSize float64,
+	err error,
+) {
+	if s.Market != nil {
+		return s.Market.GetDepth(symbol)
+	}
+
+	if depth, ok := s.Snapshot.Depth[symbol]; ok {
+		return depth.Bid, depth.BidSize, depth.Ask, depth.AskSize, nil
+	}
+
+	markPrice, _ := s.markPrice(symbol).Float64()
+
+	if markPrice == 0 {
+		return 0, 0, 0, 0, fmt.Errorf("no depth of %s", symbol)
+	}
+
+	return markPrice, math.MaxFloat64, markPrice, math.MaxFloat64, nil
+}
+
+func (s *Simulator) GetPositionRisk() ([]models.BinanceOrder, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	positions := make([]models.BinanceOrder, 0)
+
+	for symbol, amount := range s.Positions {
+		if amount.IsZero() {
+			continue
+		}
+
+		positions = append(positions, models.BinanceOrder{
+			Symbol:       symbol,
+			PositionSide: "BOTH",
+			PositionAmt:  amount.String(),
+			MarkPrice:    s.markPrice(symbol).String(),
+			MarginType:   "cross",
+		})
+	}
+
+	return positions, nil
+}
+
+func (s *Simulator) SetLeverage(symbol string, leverage int) error {
+	return nil
+}
+
+func (s *Simulator) PlaceBatchOrders(orders []models.BinancePlaceOrder) ([]models.BinanceOrderResult, error) {
+	results := make([]models.BinanceOrderResult, 0)
+
+	for _, v := range orders {
+		results = append(results, s.fill(v))
+	}
+
+	return results, nil
+}
+
+func (s *Simulator) fill(order models.BinancePlaceOrder) models.BinanceOrderResult {
+	bid, _, ask, _, err := s.GetDepth(order.Symbol)
+	if err != nil {
+		return models.BinanceOrderResult{Code: -1121, Msg: err.Error()}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	quantity, _ := decimal.NewFromString(order.Quantity)
+	position := s.Positions[order.Symbol]
+
+	price := decimal.NewFromFloat(bid)
+	signed := quantity.Neg()
+
+	if order.Side == "BUY" {
+		price = decimal.NewFromFloat(ask)
+		signed = quantity
+	}
+
+	if order.ReduceOnly == "true" {
+		if position.IsZero() || position.Sign() == signed.Sign() {
+			return models.BinanceOrderResult{Code: -2022, Msg: "ReduceOnly Order is rejected."}
+		}
+
+		if quantity.GreaterThan(position.Abs()) {
+			quantity = position.Abs()
+			signed = position.Neg()
+		}
+	}
+
+	if !quantity.IsPositive() {
+		return models.BinanceOrderResult{Code: -4003, Msg: "Quantity less than or equal to zero."}
+	}
+
+	fee := quantity.Mul(price).Mul(decimal.NewFromFloat(s.Fee))
+
+	s.Positions[order.Symbol] = position.Add(signed)
+	s.Cash = s.Cash.Sub(signed.Mul(price)).Sub(fee)
+	s.Fees = s.Fees.Add(fee)
+	s.Orders += 1
+	s.orderID += 1
+
+	s.Incomes = append(s.Incomes, models.BinanceIncome{
+		Symbol:     order.Symbol,
+		IncomeType: INCOME_COMMISSION,
+		Income:     fee.Neg().String(),
+		Time:       s.Snapshot.GetTime(),
+		TranID:     int64(len(s.Incomes) + 1),
+	})
+
+	return models.BinanceOrderResult{
+		OrderID:     s.orderID,
+		Symbol:      order.Symbol,
+		Side:        order.Side,
+		Status:      "FILLED",
+		OrigQty:     order.Quantity,
+		ExecutedQty: quantity.String(),
+		AvgPrice:    price.String(),
+		Fills: []models.BinanceTrade{
+			{
+				OrderID:    s.orderID,
+				Price:      price.String(),
+				Qty:        quantity.String(),
+				Commission: fee.String(),
+				Time:       s.Snapshot.GetTime(),
+			},
+		},
+	}
+}
+
+func (s *Simulator) GetBalance() ([]models.BinanceBalance, error) {
+	return nil, nil
+}
+
+func (s *Simulator) GetAccount() (models.BinanceAccount, error) {
+	return models.BinanceAccount{
+		TotalMaintMargin:   "0",
+		TotalMarginBalance: decimal.NewFromFloat(s.Equity()).String(),
+	}, nil
+}
+
+func (s *Simulator) ModifyMargin(symbol string, amount float64, add bool) error {
+	return nil
+}
+
+func (s *Simulator) GetExchangeInfo() ([]models.BinanceSymbolInfo, error) {
+	return nil, nil
+}
+
+func (s *Simulator) GetOrderTrades(symbol string, orderID int64) ([]models.BinanceTrade, error) {
+	return nil, nil
+}
+
+func (s *Simulator) GetIncome(symbol, incomeType string, startTime int64) ([]models.BinanceIncome, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return filter(s.Incomes, func(v models.BinanceIncome) bool {
+		return v.Symbol == symbol && v.IncomeType == incomeType && v.Time >= startTime
+	}), nil
+}
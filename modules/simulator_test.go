@@ -0,0 +1,85 @@
+package modules
+
+import (
+	"testing"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	binance "github.com/CapsLock-Studio/binance-premium-index/models"
+)
+
+type fakeProvider struct {
+	Hedge []binance.BinanceHedge
+}
+
+func (f *fakeProvider) GetHedge() ([]binance.BinanceHedge, error) {
+	return f.Hedge, nil
+}
+
+func TestSimulatorDryRun(t *testing.T) {
+	market := &fakeExchange{
+		Depth: map[string][4]float64{
+			"LDOUSDT": {2, 100, 2.1, 100},
+			"LDOUSDC": {2, 100, 2.1, 100},
+		},
+	}
+
+	simulator := NewSimulator(SIMULATED_FEE)
+	simulator.Market = market
+	simulator.Upstream = &fakeProvider{Hedge: newTestSnapshot(testFundingTime, "0.001", "0.0001").Hedge}
+
+	core := newTestCore(simulator)
+	core.Setting.DryRun = true
+	core.Setting.Leverage = 1
+	core.Setting.Difference = DEFAULT_DIFFERENCE
+	core.Provider = simulator
+
+	logger := logrus.NewEntry(logrus.New())
+
+	core.Prepare(logger)
+	core.Tick(logger)
+
+	// nothing reaches the real exchange
+	assert.Empty(t, market.Orders)
+
+	positions, _ := simulator.GetPositionRisk()
+	assert.Len(t, positions, 2)
+
+	for _, v := range positions {
+		switch v.Symbol {
+		case "LDOUSDT":
+			assert.Equal(t, "-1", v.PositionAmt)
+		case "LDOUSDC":
+			assert.Equal(t, "1", v.PositionAmt)
+		}
+	}
+
+	// sold at 2, bought at 2.1 and marked at 2, 0.05% fee on both
+	fees, _ := simulator.Fees.Float64()
+	assert.InDelta(t, 0.00205, fees, 1e-9)
+	assert.InDelta(t, -0.10205, simulator.Equity(), 1e-9)
+
+	commissions, _ := simulator.GetIncome("LDOUSDC", INCOME_COMMISSION, 0)
+	assert.Len(t, commissions, 1)
+	assert.Equal(t, "-0.00105", commissions[0].Income)
+}
+
+func TestSimulatorReduceOnly(t *testing.T) {
+	simulator := NewSimulator(0)
+	simulator.Feed(newTestSnapshot(testFundingTime, "0", "0"))
+
+	results, _ := simulator.PlaceBatchOrders([]models.BinancePlaceOrder{
+		{Type: "MARKET", Symbol: "LDOUSDT", Side: "SELL", Quantity: "1", ReduceOnly: "true"},
+		{Type: "MARKET", Symbol: "LDOUSDC", Side: "BUY", Quantity: "2"},
+		{Type: "MARKET", Symbol: "LDOUSDC", Side: "SELL", Quantity: "3", ReduceOnly: "true"},
+	})
+
+	assert.True(t, results[0].Rejected())
+	assert.False(t, results[1].Rejected())
+
+	// reduce only never flips the position
+	assert.Equal(t, "2", results[2].ExecutedQty)
+	assert.True(t, simulator.Positions["LDOUSDC"].IsZero())
+}
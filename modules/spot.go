@@ -0,0 +1,139 @@
+package modules
+
+import (
+	"strconv"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/parnurzeal/gorequest"
+)
+
+const (
+	BINANCE_SPOT_ENDPOINT string = "https://api.binance.com"
+	BINANCE_SPOT_DEPTH    string = "/api/v3/depth"
+	BINANCE_SPOT_ORDER    string = "/api/v3/order"
+	BINANCE_SPOT_ACCOUNT  string = "/api/v3/account"
+	BINANCE_SPOT_INFO     string = "/api/v3/exchangeInfo"
+)
+
+// SpotExchange is the long leg of the spot & perp hedge mode.
+type SpotExchange interface {
+	GetDepth(symbol string) (bid, bidSize, ask, askSize float64, err error)
+	GetAssetBalance(asset string) (float64, error)
+	GetFreeBalance(asset string) (float64, error)
+	PlaceOrder(order models.BinancePlaceOrder) (models.BinanceOrderResult, error)
+	GetExchangeInfo() ([]models.BinanceSymbolInfo, error)
+}
+
+// BinanceSpot shares signing with the futures client, only the host differs.
+type BinanceSpot struct {
+	*Binance
+}
+
+func NewBinanceSpot(apiKey, apiSecret string) *BinanceSpot {
+	b := NewBinance(apiKey, apiSecret)
+	b.Endpoint = BINANCE_SPOT_ENDPOINT
+
+	spot := &BinanceSpot{
+		Binance: b,
+	}
+
+	b.Clock = NewClock(spot.GetServerTime)
+
+	return spot
+}
+
+func (s *BinanceSpot) GetDepth(
+	symbol string,
+) (
+	bid,
+	bidSize,
+	ask,
+	askSize float64,
+	err error,
+) {
+	return s.getDepth(BINANCE_SPOT_DEPTH, symbol)
+}
+
+func (s *BinanceSpot) GetServerTime() (int64, error) {
+	return s.getServerTime(BINANCE_SPOT_TIME)
+}
+
+func (s *BinanceSpot) GetExchangeInfo() ([]models.BinanceSymbolInfo, error) {
+	return s.getExchangeInfo(BINANCE_SPOT_INFO)
+}
+
+// GetAssetBalance returns free + locked balance of asset.
+func (s *BinanceSpot) GetAssetBalance(asset string) (float64, error) {
+	balance, err := s.getBalance(asset)
+	if err != nil {
+		return 0, err
+	}
+
+	free, _ := strconv.ParseFloat(balance.Free, 64)
+	locked, _ := strconv.ParseFloat(balance.Locked, 64)
+
+	return free + locked, nil
+}
+
+// GetFreeBalance returns the balance of asset that can be sold right away.
+func (s *BinanceSpot) GetFreeBalance(asset string) (float64, error) {
+	balance, err := s.getBalance(asset)
+	if err != nil {
+		return 0, err
+	}
+
+	free, _ := strconv.ParseFloat(balance.Free, 64)
+
+	return free, nil
+}
+
+func (s *BinanceSpot) getBalance(asset string) (models.BinanceSpotBalance, error) {
+	account := struct {
+		Balances []models.BinanceSpotBalance `json:"balances"`
+	}{}
+
+	_, err := s.Request(
+		BINANCE_SPOT_ACCOUNT,
+		gorequest.GET,
+		map[string]string{},
+		&account,
+	)
+
+	if err != nil {
+		return models.BinanceSpotBalance{}, err
+	}
+
+	for _, v := range account.Balances {
+		if v.Asset == asset {
+			return v, nil
+		}
+	}
+
+	return models.BinanceSpotBalance{}, nil
+}
+
+func (s *BinanceSpot) PlaceOrder(order models.BinancePlaceOrder) (models.BinanceOrderResult, error) {
+	var result models.BinanceOrderResult
+
+	_, err := s.Request(
+		BINANCE_SPOT_ORDER,
+		gorequest.POST,
+		map[string]string{
+			"symbol":   order.Symbol,
+			"side":     order.Side,
+			"type":     order.Type,
+			"quantity": order.Quantity,
+		},
+		&result,
+	)
+
+	if err != nil {
+		return result, err
+	}
+
+	if result.Rejected() {
+		return result, &models.BinanceError{Code: result.Code, Msg: result.Msg}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,61 @@
+package modules
+
+import (
+	"encoding/json"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/sirupsen/logrus"
+)
+
+// StateStore keeps the loop state of bots between restarts.
+type StateStore interface {
+	SaveRuntime(ID string, state *models.CoreState) error
+	GetRuntime(ID string) (*models.CoreState, error)
+}
+
+// Restore loads the last checkpoint of this bot, it reports whether one was found.
+func (c *Core) Restore(logger *logrus.Entry) bool {
+	if c.Store == nil || c.ID == nil {
+		return false
+	}
+
+	state, err := c.Store.GetRuntime(*c.ID)
+	if err != nil {
+		logger.Error(err)
+		return false
+	}
+
+	if state == nil {
+		return false
+	}
+
+	logger.Info("resume from checkpoint")
+
+	c.State = state
+	c.lastCheckpoint, _ = json.Marshal(state)
+
+	return true
+}
+
+// Checkpoint saves the loop state whenever it has changed since the last save.
+func (c *Core) Checkpoint(logger *logrus.Entry) {
+	if c.Store == nil || c.ID == nil || c.State == nil {
+		return
+	}
+
+	c.State.Total = c.Setting.Total
+	c.State.Reduce = c.Setting.Reduce
+
+	body, _ := json.Marshal(c.State)
+
+	if string(body) == string(c.lastCheckpoint) {
+		return
+	}
+
+	if err := c.Store.SaveRuntime(*c.ID, c.State); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	c.lastCheckpoint = body
+}
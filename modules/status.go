@@ -0,0 +1,69 @@
+package modules
+
+import (
+	"time"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+)
+
+// UpdateStatus takes a snapshot of the loop for Status and RunningSetting, only NewCore and the loop call it.
+func (c *Core) UpdateStatus() {
+	status := models.BotStatus{
+		Symbol:         c.Setting.Symbol,
+		PrimaryQuote:   c.Setting.PrimaryQuote,
+		SecondaryQuote: c.Setting.SecondaryQuote,
+		Spot:           c.Setting.Spot,
+		DryRun:         c.Setting.DryRun,
+		Paused:         c.Paused(),
+		Reduce:         c.Setting.Reduce,
+		Arbitrage:      c.Setting.Arbitrage,
+		Total:          c.Setting.Total,
+		Unwinding:      c.unwinding,
+		MarkPriceGap:   c.lastMarkPriceGap,
+		FundingRateGap: c.lastFundingRateGap,
+		UpdatedAt:      time.Now().UnixMilli(),
+	}
+
+	if c.ID != nil {
+		status.ID = *c.ID
+	}
+
+	if !c.lastOrder.IsZero() {
+		status.LastOrderTime = c.lastOrder.UnixMilli()
+	}
+
+	if c.lastError != nil {
+		status.LastError = c.lastError.Error()
+		status.LastErrorTime = c.lastErrorTime.UnixMilli()
+	}
+
+	if c.State != nil {
+		if c.State.CurrentDirection != nil {
+			direction := *c.State.CurrentDirection
+			status.Direction = &direction
+		}
+
+		status.TotalQuantity = c.State.TotalQuantity
+		status.Step = c.State.Step
+		status.CurrentProgressBarTotal = c.State.CurrentProgressBarTotal
+		status.MaxProgressBar = c.State.MaxProgressBar
+		status.Hedged = c.State.Hedged
+		status.Reverse = c.State.FundingRateReverseMode
+		status.ArbitrageTriggered = c.State.ArbitrageTriggered
+		status.Unwinding = c.unwinding || c.State.Unwinding
+	}
+
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+
+	c.status = status
+	c.running = *c.Setting
+}
+
+// Status returns the last snapshot of the loop, safe to call from any goroutine.
+func (c *Core) Status() models.BotStatus {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+
+	return c.status
+}
@@ -0,0 +1,50 @@
+package modules
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatus(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+	ID := "bot"
+
+	core := newTestCore(&fakeExchange{})
+	core.ID = &ID
+
+	core.UpdateStatus()
+
+	assert.Equal(t, "bot", core.Status().ID)
+	assert.Nil(t, core.Status().Direction)
+
+	direction := true
+	core.State = &models.CoreState{CurrentDirection: &direction, Step: -1, TotalQuantity: 4, FundingRateReverseMode: true}
+	core.lastMarkPriceGap = 0.02
+	core.LogError(logger, errors.New("timeout"))
+	core.CheckOrders(logger, []models.BinanceOrderResult{{OrderID: 1, Symbol: "LDOUSDT"}})
+	core.Pause()
+
+	// nothing changes until the loop takes a snapshot
+	assert.Zero(t, core.Status().TotalQuantity)
+
+	core.UpdateStatus()
+
+	status := core.Status()
+
+	assert.True(t, *status.Direction)
+	assert.Equal(t, 4.0, status.TotalQuantity)
+	assert.Equal(t, -1, status.Step)
+	assert.True(t, status.Reverse)
+	assert.True(t, status.Paused)
+	assert.Equal(t, 0.02, status.MarkPriceGap)
+	assert.Equal(t, "timeout", status.LastError)
+	assert.NotZero(t, status.LastOrderTime)
+
+	// the snapshot doesn't follow the loop state
+	direction = false
+	assert.True(t, *core.Status().Direction)
+}
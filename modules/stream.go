@@ -0,0 +1,295 @@
+package modules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/websocket"
+
+	binance "github.com/CapsLock-Studio/binance-premium-index/models"
+)
+
+const (
+	BINANCE_FAPI_STREAM string = "wss://fstream.binance.com"
+
+	STREAM_MIN_BACKOFF time.Duration = 1 * time.Second
+	STREAM_MAX_BACKOFF time.Duration = 1 * time.Minute
+	STREAM_TIMEOUT     time.Duration = 1 * time.Minute
+	STREAM_STALE       time.Duration = 10 * time.Second
+)
+
+// Stream keeps the latest depth5 and mark price of symbols in memory
+// from Binance market streams, reconnecting with backoff.
+type Stream struct {
+	Endpoint   string
+	Symbols    []string
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	Updated    chan bool
+	depth      map[string]models.Depth
+	premiums   map[string]binance.BinancePremium
+	updatedAt  map[string]time.Time
+	conn       *websocket.Conn
+	closed     bool
+	mutex      sync.RWMutex
+}
+
+func NewStream(symbols ...string) *Stream {
+	return &Stream{
+		Endpoint:   BINANCE_FAPI_STREAM,
+		Symbols:    symbols,
+		MinBackoff: STREAM_MIN_BACKOFF,
+		MaxBackoff: STREAM_MAX_BACKOFF,
+		Updated:    make(chan bool, 1),
+		depth:      make(map[string]models.Depth),
+		premiums:   make(map[string]binance.BinancePremium),
+		updatedAt:  make(map[string]time.Time),
+	}
+}
+
+// Run keeps the stream connected until Close.
+func (s *Stream) Run() {
+	backoff := s.MinBackoff
+
+	for {
+		received, err := s.connect()
+
+		if s.isClosed() {
+			return
+		}
+
+		// a connection that worked starts backing off from scratch
+		if received {
+			backoff = s.MinBackoff
+		}
+
+		logrus.
+			WithField("symbols", s.Symbols).
+			WithField("backoff", backoff).
+			Warn("stream disconnected, ", err)
+
+		time.Sleep(backoff)
+
+		backoff *= 2
+
+		if backoff > s.MaxBackoff {
+			backoff = s.MaxBackoff
+		}
+	}
+}
+
+func (s *Stream) Close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closed = true
+
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+func (s *Stream) isClosed() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.closed
+}
+
+// connect subscribes every symbol and reads until the connection breaks.
+func (s *Stream) connect() (received bool, err error) {
+	conn, err := websocket.Dial(s.Endpoint+"/stream", "", "http://localhost/")
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	s.mutex.Lock()
+
+	if s.closed {
+		s.mutex.Unlock()
+		return false, errors.New("stream is closed")
+	}
+
+	s.conn = conn
+	s.mutex.Unlock()
+
+	params := make([]string, 0)
+
+	for _, symbol := range s.Symbols {
+		params = append(params, strings.ToLower(symbol)+"@depth5", strings.ToLower(symbol)+"@markPrice")
+	}
+
+	if err := websocket.JSON.Send(conn, map[string]any{
+		"method": "SUBSCRIBE",
+		"params": params,
+		"id":     1,
+	}); err != nil {
+		return false, err
+	}
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(STREAM_TIMEOUT))
+
+		message := struct {
+			Stream string          `json:"stream"`
+			Data   json.RawMessage `json:"data"`
+		}{}
+
+		if err := websocket.JSON.Receive(conn, &message); err != nil {
+			return received, err
+		}
+
+		// subscribe responses have no stream
+		if message.Stream == "" {
+			continue
+		}
+
+		if err := s.handle(message.Stream, message.Data); err != nil {
+			logrus.WithField("stream", message.Stream).Error(err)
+			continue
+		}
+
+		received = true
+	}
+}
+
+func (s *Stream) handle(stream string, data []byte) error {
+	event := struct {
+		Symbol          string     `json:"s"`
+		Time            int64      `json:"E"`
+		Bids            [][]string `json:"b"`
+		Asks            [][]string `json:"a"`
+		MarkPrice       string     `json:"p"`
+		IndexPrice      string     `json:"i"`
+		SettlePrice     string     `json:"P"`
+		FundingRate     string     `json:"r"`
+		NextFundingTime int64      `json:"T"`
+	}{}
+
+	if err := json.Unmarshal(data, &event); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch {
+	case strings.HasSuffix(stream, "@depth5"):
+		if len(event.Bids) == 0 || len(event.Asks) == 0 {
+			return fmt.Errorf("empty book of %s", event.Symbol)
+		}
+
+		// same level as the REST depth
+		bid := event.Bids[len(event.Bids)-1]
+		ask := event.Asks[len(event.Asks)-1]
+
+		depth := models.Depth{}
+		depth.Bid, _ = strconv.ParseFloat(bid[0], 64)
+		depth.BidSize, _ = strconv.ParseFloat(bid[1], 64)
+		depth.Ask, _ = strconv.ParseFloat(ask[0], 64)
+		depth.AskSize, _ = strconv.ParseFloat(ask[1], 64)
+
+		s.depth[event.Symbol] = depth
+		s.updatedAt[event.Symbol] = time.Now()
+
+		select {
+		case s.Updated <- true:
+		default:
+		}
+	case strings.HasSuffix(stream, "@markPrice"):
+		s.premiums[event.Symbol] = binance.BinancePremium{
+			Symbol:               event.Symbol,
+			MarkPrice:            event.MarkPrice,
+			IndexPrice:           event.IndexPrice,
+			EstimatedSettlePrice: event.SettlePrice,
+			LastFundingRate:      event.FundingRate,
+			NextFundingTime:      int(event.NextFundingTime),
+			Time:                 int(event.Time),
+		}
+	}
+
+	return nil
+}
+
+// GetDepth returns the latest book of symbol unless it's stale.
+func (s *Stream) GetDepth(symbol string) (models.Depth, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	depth, ok := s.depth[symbol]
+
+	if !ok {
+		return depth, fmt.Errorf("no book of %s yet", symbol)
+	}
+
+	if age := time.Since(s.updatedAt[symbol]); age > STREAM_STALE {
+		return depth, fmt.Errorf("book of %s is stale for %s", symbol, age.Truncate(time.Second))
+	}
+
+	return depth, nil
+}
+
+// GetPremiumIndex returns mark price and funding rate of every streamed symbol.
+func (s *Stream) GetPremiumIndex() ([]binance.BinancePremium, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	premiums := make([]binance.BinancePremium, 0)
+
+	for _, symbol := range s.Symbols {
+		v, ok := s.premiums[symbol]
+
+		if !ok {
+			return nil, fmt.Errorf("no mark price of %s yet", symbol)
+		}
+
+		if age := time.Since(time.UnixMilli(int64(v.Time))); age > STREAM_STALE {
+			return nil, fmt.Errorf("mark price of %s is stale for %s", symbol, age.Truncate(time.Second))
+		}
+
+		premiums = append(premiums, v)
+	}
+
+	return premiums, nil
+}
+
+// StreamExchange reads market data from a stream, and from Binance directly
+// while the stream has nothing fresh.
+type StreamExchange struct {
+	*Binance
+	Stream *Stream
+}
+
+func (e *StreamExchange) GetDepth(
+	symbol string,
+) (
+	bid,
+	bidSize,
+	ask,
+	askSize float64,
+	err error,
+) {
+	if v, err := e.Stream.GetDepth(symbol); err == nil {
+		return v.Bid, v.BidSize, v.Ask, v.AskSize, nil
+	}
+
+	return e.Binance.GetDepth(symbol)
+}
+
+func (e *StreamExchange) GetPremiumIndex() ([]binance.BinancePremium, error) {
+	premiums, err := e.Stream.GetPremiumIndex()
+	if err != nil {
+		logrus.Warn(err)
+		return e.Binance.GetPremiumIndex()
+	}
+
+	return premiums, nil
+}
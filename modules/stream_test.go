@@ -0,0 +1,95 @@
+package modules
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+func TestStream(t *testing.T) {
+	connections := 0
+	subscribed := make([][]string, 0)
+	mutex := &sync.Mutex{}
+
+	server := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		request := struct {
+			Method string   `json:"method"`
+			Params []string `json:"params"`
+		}{}
+
+		if err := websocket.JSON.Receive(conn, &request); err != nil {
+			return
+		}
+
+		mutex.Lock()
+		connections += 1
+		count := connections
+		subscribed = append(subscribed, request.Params)
+		mutex.Unlock()
+
+		websocket.Message.Send(conn, `{"result":null,"id":1}`)
+
+		now := time.Now().UnixMilli()
+
+		if count == 1 {
+			websocket.Message.Send(conn, `{"stream":"ldousdt@depth5","data":{"s":"LDOUSDT","b":[["1.9","10"],["1.8","20"]],"a":[["2.1","30"],["2.2","40"]]}}`)
+
+			// dropped, the client has to reconnect and subscribe again
+			return
+		}
+
+		websocket.Message.Send(conn, `{"stream":"ldousdt@depth5","data":{"s":"LDOUSDT","b":[["1.95","5"]],"a":[["2.05","6"]]}}`)
+		websocket.Message.Send(conn, `{"stream":"ldousdt@markPrice","data":{"s":"LDOUSDT","E":`+strconv.FormatInt(now, 10)+`,"p":"2.01","r":"0.0001","T":1700000000000}}`)
+
+		// hold the connection until the client closes it
+		var ignored string
+		websocket.Message.Receive(conn, &ignored)
+	}))
+	defer server.Close()
+
+	stream := NewStream("LDOUSDT")
+	stream.Endpoint = "ws" + strings.TrimPrefix(server.URL, "http")
+	stream.MinBackoff = 10 * time.Millisecond
+
+	go stream.Run()
+	defer stream.Close()
+
+	assert.Eventually(t, func() bool {
+		depth, err := stream.GetDepth("LDOUSDT")
+
+		return err == nil && depth.Bid == 1.95
+	}, 2*time.Second, 10*time.Millisecond)
+
+	assert.Eventually(t, func() bool {
+		_, err := stream.GetPremiumIndex()
+
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond)
+
+	mutex.Lock()
+	assert.Equal(t, 2, connections)
+	assert.Equal(t, []string{"ldousdt@depth5", "ldousdt@markPrice"}, subscribed[1])
+	mutex.Unlock()
+
+	depth, _ := stream.GetDepth("LDOUSDT")
+	assert.Equal(t, 6.0, depth.AskSize)
+
+	premiums, _ := stream.GetPremiumIndex()
+	assert.Equal(t, "2.01", premiums[0].MarkPrice)
+	assert.Equal(t, 1700000000000, premiums[0].NextFundingTime)
+
+	select {
+	case <-stream.Updated:
+	default:
+		t.Error("no update signaled")
+	}
+
+	_, err := stream.GetDepth("LDOUSDC")
+	assert.EqualError(t, err, "no book of LDOUSDC yet")
+}
@@ -0,0 +1,89 @@
+package modules
+
+import (
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+// Update queues a setting change, the loop applies it before its next tick
+// so a batch never sees half of it.
+func (c *Core) Update(patch models.SettingPatch) {
+	c.updateMutex.Lock()
+	defer c.updateMutex.Unlock()
+
+	c.updates = append(c.updates, patch)
+}
+
+// ReceiveUpdates applies queued setting changes, keeping direction and progress of the loop.
+func (c *Core) ReceiveUpdates(logger *logrus.Entry) {
+	c.updateMutex.Lock()
+	patches := c.updates
+	c.updates = nil
+	c.updateMutex.Unlock()
+
+	for _, patch := range patches {
+		total := c.Setting.Total
+
+		ApplyPatch(c.Setting, patch)
+
+		// what's left to open or close moves with the total
+		if c.State != nil && !c.Setting.Spot {
+			c.State.TotalQuantity, _ = decimal.
+				NewFromFloat(c.State.TotalQuantity).
+				Add(decimal.NewFromFloat(c.Setting.Total)).
+				Sub(decimal.NewFromFloat(total)).
+				Float64()
+
+			if c.State.TotalQuantity < 0 {
+				c.State.TotalQuantity = 0
+			}
+
+			c.progressBarTotal = c.getProgressBarTotal()
+
+			// reverse mode only closes what was opened
+			if !c.State.FundingRateReverseMode || c.State.MaxProgressBar > c.progressBarTotal {
+				c.State.MaxProgressBar = c.progressBarTotal
+			}
+		}
+
+		logger.Info("setting updated")
+
+		c.Publish(models.EventMessage{Type: "update", Message: patch})
+	}
+}
+
+// ApplyPatch applies patch to s the way the loop runs it,
+// arbitrage mode keeps its total at the quantity.
+func ApplyPatch(s *models.ConfigSetting, patch models.SettingPatch) {
+	patch.Apply(s)
+
+	if s.Arbitrage {
+		s.Total = s.Quantity
+	}
+}
+
+// RunningSetting is the setting of the loop as of its last tick with queued updates applied,
+// safe to call from any goroutine.
+func (c *Core) RunningSetting() models.ConfigSetting {
+	c.statusMutex.Lock()
+	setting := c.running
+	c.statusMutex.Unlock()
+
+	c.updateMutex.Lock()
+	defer c.updateMutex.Unlock()
+
+	for _, patch := range c.updates {
+		ApplyPatch(&setting, patch)
+	}
+
+	return setting
+}
+
+// Rotate signs every further request of the bot with a new key pair,
+// the user stream renews its listenKey with it on the next reconnect.
+func (c *Core) Rotate(apiKey, apiSecret string) {
+	for _, client := range c.clients {
+		client.SetKeys(apiKey, apiSecret)
+	}
+}
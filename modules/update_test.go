@@ -0,0 +1,122 @@
+package modules
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"testing"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReceiveUpdates(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+
+	core := newTestCore(&fakeExchange{})
+	core.Prepare(logger)
+
+	direction := true
+	core.State.CurrentDirection = &direction
+	core.State.TotalQuantity = 4
+
+	difference := 0.1
+	total := 20.0
+	quantity := 3.0
+
+	core.Update(models.SettingPatch{Difference: &difference, Total: &total})
+	core.Update(models.SettingPatch{Quantity: &quantity})
+
+	// nothing changes until the loop picks it up
+	assert.Equal(t, 10.0, core.Setting.Total)
+	assert.Equal(t, 20.0, core.RunningSetting().Total)
+
+	core.ReceiveUpdates(logger)
+
+	assert.Equal(t, 0.1, core.Setting.Difference)
+	assert.Equal(t, 20.0, core.Setting.Total)
+	assert.Equal(t, 3.0, core.Setting.Quantity)
+	assert.Equal(t, 14.0, core.State.TotalQuantity)
+	assert.Equal(t, 7, core.progressBarTotal)
+	assert.Equal(t, 7, core.State.MaxProgressBar)
+	assert.True(t, *core.State.CurrentDirection)
+
+	total = 2
+
+	core.Update(models.SettingPatch{Total: &total})
+	core.ReceiveUpdates(logger)
+
+	assert.Equal(t, 0.0, core.State.TotalQuantity)
+
+	webhook := "http://localhost/hook"
+
+	core.Update(models.SettingPatch{Webhook: &webhook})
+	core.ReceiveUpdates(logger)
+	core.Setting.Webhook = ""
+
+	// events keep the webhook they were created with
+	var event models.EventMessage
+	for len(core.EventPublisher) > 0 {
+		event = <-core.EventPublisher
+	}
+
+	assert.Equal(t, "update", event.Type)
+	assert.Equal(t, "LDO", event.Symbol)
+	assert.Equal(t, webhook, event.Webhook)
+}
+
+func TestReceiveUpdatesArbitrage(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+
+	core := newTestCore(&fakeExchange{})
+	core.Setting.Arbitrage = true
+	core.Setting.Total = 0
+	core.Prepare(logger)
+	core.UpdateStatus()
+
+	assert.Equal(t, 1.0, core.RunningSetting().Total)
+	assert.Equal(t, .08, core.RunningSetting().Difference)
+
+	quantity := 2.0
+
+	core.Update(models.SettingPatch{Quantity: &quantity})
+
+	// total follows quantity in arbitrage mode
+	assert.Equal(t, 2.0, core.RunningSetting().Total)
+
+	core.ReceiveUpdates(logger)
+
+	assert.Equal(t, 2.0, core.Setting.Total)
+	assert.Equal(t, 2.0, core.State.TotalQuantity)
+	assert.Equal(t, 1, core.State.MaxProgressBar)
+}
+
+func TestRotate(t *testing.T) {
+	setting := &models.ConfigSetting{Symbol: "LDO", Spot: true}
+	setting.ApiKey = "old"
+	setting.ApiSecret = "old-secret"
+	setting.PrimaryQuote = DEFAULT_PRIMARY_QUOTE
+
+	core := NewBinanceCore(setting, nil, NewLimiter(), nil)
+	assert.Len(t, core.clients, 2)
+
+	core.Rotate("new", "new-secret")
+
+	for _, client := range core.clients {
+		client.Clock = nil
+
+		req := client.MakeRequest("/", "GET", map[string]string{"symbol": "LDOUSDT"})
+		assert.Equal(t, "new", req.Header.Get("X-MBX-APIKEY"))
+
+		query, _ := url.Parse(req.Url)
+		params := query.Query()
+		signature := params.Get("signature")
+		params.Del("signature")
+
+		mac := hmac.New(sha256.New, []byte("new-secret"))
+		mac.Write([]byte(params.Encode()))
+		assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), signature)
+	}
+}
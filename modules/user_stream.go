@@ -0,0 +1,520 @@
+package modules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/parnurzeal/gorequest"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	BINANCE_FAPI_LISTEN_KEY string = "/fapi/v1/listenKey"
+
+	USER_STREAM_KEEPALIVE time.Duration = 30 * time.Minute
+	USER_STREAM_EVENTS    int           = 100
+
+	USER_EVENT_ORDER       string = "order"
+	USER_EVENT_ACCOUNT     string = "account"
+	USER_EVENT_MARGIN_CALL string = "marginCall"
+	USER_EVENT_LIQUIDATION string = "liquidation"
+)
+
+func (b *Binance) CreateListenKey() (string, error) {
+	result := struct {
+		ListenKey string `json:"listenKey"`
+		Code      int    `json:"code"`
+		Msg       string `json:"msg"`
+	}{}
+
+	_, err := b.Request(BINANCE_FAPI_LISTEN_KEY, gorequest.POST, nil, &result)
+
+	if err != nil {
+		return "", err
+	}
+
+	if result.ListenKey == "" {
+		return "", fmt.Errorf("binance error %d: %s", result.Code, result.Msg)
+	}
+
+	return result.ListenKey, nil
+}
+
+func (b *Binance) KeepAliveListenKey() error {
+	_, err := b.Request(BINANCE_FAPI_LISTEN_KEY, gorequest.PUT, nil, nil)
+
+	return err
+}
+
+func (b *Binance) CloseListenKey() error {
+	_, err := b.Request(BINANCE_FAPI_LISTEN_KEY, gorequest.DELETE, nil, nil)
+
+	return err
+}
+
+// userStreams is shared by every bot of the process.
+var userStreams = NewUserStreams()
+
+// UserStreams keeps one user data stream per API key,
+// Binance has a single listenKey per account and closing it ends every stream on it.
+type UserStreams struct {
+	Endpoint string
+	streams  map[string]*UserStream
+	mutex    sync.Mutex
+}
+
+func NewUserStreams() *UserStreams {
+	return &UserStreams{
+		Endpoint: BINANCE_FAPI_STREAM,
+		streams:  make(map[string]*UserStream),
+	}
+}
+
+func (s *UserStreams) open(subscription *UserSubscription) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if subscription.stream != nil {
+		return
+	}
+
+	key, _ := subscription.Exchange.GetKeys()
+	stream := s.streams[key]
+
+	if stream == nil {
+		stream = NewUserStream(subscription.Exchange)
+		stream.Endpoint = s.Endpoint
+		s.streams[key] = stream
+
+		go stream.Run()
+	}
+
+	stream.subscribe(subscription)
+
+	subscription.key = key
+	subscription.stream = stream
+}
+
+func (s *UserStreams) close(subscription *UserSubscription) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stream := subscription.stream
+
+	if stream == nil {
+		return
+	}
+
+	subscription.stream = nil
+
+	// the last subscriber takes the listenKey down
+	if stream.unsubscribe(subscription) == 0 {
+		delete(s.streams, subscription.key)
+		stream.Close()
+	}
+}
+
+// UserSubscription receives the user data events of an account once it's open.
+type UserSubscription struct {
+	Exchange *Binance
+	Events   chan models.EventMessage
+	streams  *UserStreams
+	stream   *UserStream
+	key      string
+}
+
+func NewUserSubscription(exchange *Binance) *UserSubscription {
+	return &UserSubscription{
+		Exchange: exchange,
+		Events:   make(chan models.EventMessage, USER_STREAM_EVENTS),
+		streams:  userStreams,
+	}
+}
+
+// Open joins the stream of the account, the first subscriber connects it.
+func (s *UserSubscription) Open() {
+	s.streams.open(s)
+}
+
+// Close leaves the stream, it's closed once nobody listens anymore.
+func (s *UserSubscription) Close() {
+	s.streams.close(s)
+}
+
+// UserStream turns the user data stream of an account into typed events for its subscribers,
+// keeping its listenKey alive and reconnecting with backoff.
+type UserStream struct {
+	Exchange    *Binance
+	Endpoint    string
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	Keepalive   time.Duration
+	subscribers map[*UserSubscription]bool
+	conn        *websocket.Conn
+	closed      bool
+	mutex       sync.Mutex
+}
+
+func NewUserStream(exchange *Binance) *UserStream {
+	return &UserStream{
+		Exchange:    exchange,
+		Endpoint:    BINANCE_FAPI_STREAM,
+		MinBackoff:  STREAM_MIN_BACKOFF,
+		MaxBackoff:  STREAM_MAX_BACKOFF,
+		Keepalive:   USER_STREAM_KEEPALIVE,
+		subscribers: make(map[*UserSubscription]bool),
+	}
+}
+
+func (u *UserStream) subscribe(subscription *UserSubscription) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	u.subscribers[subscription] = true
+}
+
+// unsubscribe returns how many subscribers are left.
+func (u *UserStream) unsubscribe(subscription *UserSubscription) int {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	delete(u.subscribers, subscription)
+
+	return len(u.subscribers)
+}
+
+func (u *UserStream) deliver(event models.EventMessage) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	for subscription := range u.subscribers {
+		select {
+		case subscription.Events <- event:
+		default:
+			logrus.Warn("user data event dropped, ", event.Type)
+		}
+	}
+}
+
+// Run keeps the user data stream connected until Close.
+func (u *UserStream) Run() {
+	backoff := u.MinBackoff
+
+	for {
+		received, err := u.connect()
+
+		if u.isClosed() {
+			return
+		}
+
+		if received {
+			backoff = u.MinBackoff
+		}
+
+		logrus.WithField("backoff", backoff).Warn("user data stream disconnected, ", err)
+
+		time.Sleep(backoff)
+
+		backoff *= 2
+
+		if backoff > u.MaxBackoff {
+			backoff = u.MaxBackoff
+		}
+	}
+}
+
+func (u *UserStream) Close() {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	u.closed = true
+
+	if u.conn != nil {
+		u.conn.Close()
+	}
+}
+
+func (u *UserStream) isClosed() bool {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	return u.closed
+}
+
+func (u *UserStream) connect() (received bool, err error) {
+	listenKey, err := u.Exchange.CreateListenKey()
+	if err != nil {
+		return false, err
+	}
+
+	conn, err := websocket.Dial(u.Endpoint+"/ws/"+listenKey, "", "http://localhost/")
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	u.mutex.Lock()
+
+	if u.closed {
+		u.mutex.Unlock()
+		u.Exchange.CloseListenKey()
+		return false, errors.New("user data stream is closed")
+	}
+
+	u.conn = conn
+	u.mutex.Unlock()
+
+	done := make(chan bool)
+	defer close(done)
+
+	go func() {
+		ticker := time.NewTicker(u.Keepalive)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := u.Exchange.KeepAliveListenKey(); err != nil {
+					logrus.Error(err)
+				}
+			}
+		}
+	}()
+
+	for {
+		// keepalive is the longest silence we expect
+		conn.SetReadDeadline(time.Now().Add(u.Keepalive + STREAM_TIMEOUT))
+
+		var data []byte
+
+		if err := websocket.Message.Receive(conn, &data); err != nil {
+			if u.isClosed() {
+				u.Exchange.CloseListenKey()
+			}
+
+			return received, err
+		}
+
+		event, err := ParseUserEvent(data)
+		if err != nil {
+			return received, err
+		}
+
+		if event == nil {
+			continue
+		}
+
+		received = true
+
+		u.deliver(*event)
+	}
+}
+
+// ParseUserEvent decodes a user data stream payload into an event,
+// nil for payloads the bot doesn't use.
+func ParseUserEvent(data []byte) (*models.EventMessage, error) {
+	type position struct {
+		Symbol         string `json:"s"`
+		PositionSide   string `json:"ps"`
+		PositionAmt    string `json:"pa"`
+		EntryPrice     string `json:"ep"`
+		MarkPrice      string `json:"mp"`
+		UnrealizedPnl  string `json:"up"`
+		MarginType     string `json:"mt"`
+		IsolatedMargin string `json:"iw"`
+		MaintMargin    string `json:"mm"`
+	}
+
+	toPositions := func(values []position) []models.AccountPosition {
+		positions := make([]models.AccountPosition, 0)
+
+		for _, v := range values {
+			positions = append(positions, models.AccountPosition{
+				Symbol:         v.Symbol,
+				PositionSide:   v.PositionSide,
+				PositionAmt:    v.PositionAmt,
+				EntryPrice:     v.EntryPrice,
+				MarkPrice:      v.MarkPrice,
+				UnrealizedPnl:  v.UnrealizedPnl,
+				MarginType:     v.MarginType,
+				IsolatedMargin: v.IsolatedMargin,
+				MaintMargin:    v.MaintMargin,
+			})
+		}
+
+		return positions
+	}
+
+	payload := struct {
+		Event       string `json:"e"`
+		Time        int64  `json:"E"`
+		CrossWallet string `json:"cw"`
+		Order       struct {
+			Symbol          string `json:"s"`
+			ClientOrderID   string `json:"c"`
+			Side            string `json:"S"`
+			Type            string `json:"o"`
+			Quantity        string `json:"q"`
+			AvgPrice        string `json:"ap"`
+			ExecutionType   string `json:"x"`
+			Status          string `json:"X"`
+			OrderID         int64  `json:"i"`
+			LastFilledQty   string `json:"l"`
+			FilledQty       string `json:"z"`
+			LastFilledPrice string `json:"L"`
+			CommissionAsset string `json:"N"`
+			Commission      string `json:"n"`
+			ReduceOnly      bool   `json:"R"`
+			RealizedPnl     string `json:"rp"`
+		} `json:"o"`
+		Account struct {
+			Reason   string `json:"m"`
+			Balances []struct {
+				Asset              string `json:"a"`
+				WalletBalance      string `json:"wb"`
+				CrossWalletBalance string `json:"cw"`
+			} `json:"B"`
+			Positions []position `json:"P"`
+		} `json:"a"`
+		Positions []position `json:"p"`
+	}{}
+
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+
+	switch payload.Event {
+	case "ORDER_TRADE_UPDATE":
+		o := payload.Order
+
+		return &models.EventMessage{
+			Type: USER_EVENT_ORDER,
+			Message: models.OrderUpdate{
+				Symbol:          o.Symbol,
+				ClientOrderID:   o.ClientOrderID,
+				OrderID:         o.OrderID,
+				Side:            o.Side,
+				Type:            o.Type,
+				ExecutionType:   o.ExecutionType,
+				Status:          o.Status,
+				Quantity:        o.Quantity,
+				AvgPrice:        o.AvgPrice,
+				LastFilledQty:   o.LastFilledQty,
+				LastFilledPrice: o.LastFilledPrice,
+				FilledQty:       o.FilledQty,
+				Commission:      o.Commission,
+				CommissionAsset: o.CommissionAsset,
+				RealizedPnl:     o.RealizedPnl,
+				ReduceOnly:      o.ReduceOnly,
+				Time:            payload.Time,
+			},
+		}, nil
+	case "ACCOUNT_UPDATE":
+		update := models.AccountUpdate{
+			Reason:    payload.Account.Reason,
+			Balances:  make([]models.AccountBalance, 0),
+			Positions: toPositions(payload.Account.Positions),
+			Time:      payload.Time,
+		}
+
+		for _, v := range payload.Account.Balances {
+			update.Balances = append(update.Balances, models.AccountBalance{
+				Asset:              v.Asset,
+				WalletBalance:      v.WalletBalance,
+				CrossWalletBalance: v.CrossWalletBalance,
+			})
+		}
+
+		return &models.EventMessage{Type: USER_EVENT_ACCOUNT, Message: update}, nil
+	case "MARGIN_CALL":
+		return &models.EventMessage{
+			Type: USER_EVENT_MARGIN_CALL,
+			Message: models.MarginCall{
+				CrossWalletBalance: payload.CrossWallet,
+				Positions:          toPositions(payload.Positions),
+				Time:               payload.Time,
+			},
+		}, nil
+	case "listenKeyExpired":
+		return nil, errors.New("listen key expired")
+	}
+
+	return nil, nil
+}
+
+// ReceiveUserEvents publishes waiting user data events of the bot's symbols
+// and remembers liquidated quantity for the next tick.
+func (c *Core) ReceiveUserEvents(logger *logrus.Entry) {
+	if c.UserStream == nil {
+		return
+	}
+
+	for {
+		select {
+		case event := <-c.UserStream.Events:
+			c.handleUserEvent(logger, event)
+		default:
+			return
+		}
+	}
+}
+
+func (c *Core) handleUserEvent(logger *logrus.Entry, event models.EventMessage) {
+	symbols := c.GetSymbols()
+
+	ours := func(v models.AccountPosition) bool {
+		return slices.Contains(symbols, v.Symbol)
+	}
+
+	switch m := event.Message.(type) {
+	case models.OrderUpdate:
+		if !slices.Contains(symbols, m.Symbol) {
+			return
+		}
+
+		if m.Liquidated() && m.Status == "FILLED" {
+			quantity, _ := decimal.NewFromString(m.FilledQty)
+
+			c.liquidated, _ = decimal.
+				NewFromFloat(c.liquidated).
+				Add(quantity).
+				Float64()
+
+			logger.
+				WithField("quantity", m.FilledQty).
+				WithField("price", m.AvgPrice).
+				Warn(m.Symbol, " has been liquidated")
+
+			event.Type = USER_EVENT_LIQUIDATION
+		}
+	case models.AccountUpdate:
+		m.Positions = filter(m.Positions, ours)
+
+		if len(m.Positions) == 0 {
+			return
+		}
+
+		event.Message = m
+	case models.MarginCall:
+		m.Positions = filter(m.Positions, ours)
+
+		if len(m.Positions) == 0 {
+			return
+		}
+
+		logger.WithField("crossWalletBalance", m.CrossWalletBalance).Warn("margin call")
+
+		event.Message = m
+	}
+
+	c.Publish(event)
+}
@@ -0,0 +1,238 @@
+package modules
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+const testLiquidation = `{"e":"ORDER_TRADE_UPDATE","E":1700000000000,"o":{"s":"LDOUSDT","c":"autoclose-1","S":"BUY","o":"LIQUIDATION","q":"3","ap":"2.5","x":"TRADE","X":"FILLED","i":7,"l":"3","z":"3","L":"2.5","n":"0.01","N":"USDT","R":false,"rp":"-1.5"}}`
+
+func TestParseUserEvent(t *testing.T) {
+	event, err := ParseUserEvent([]byte(testLiquidation))
+
+	assert.NoError(t, err)
+	assert.Equal(t, USER_EVENT_ORDER, event.Type)
+
+	order := event.Message.(models.OrderUpdate)
+	assert.Equal(t, "LDOUSDT", order.Symbol)
+	assert.Equal(t, int64(7), order.OrderID)
+	assert.Equal(t, "3", order.FilledQty)
+	assert.True(t, order.Liquidated())
+
+	event, err = ParseUserEvent([]byte(`{"e":"ACCOUNT_UPDATE","E":1,"a":{"m":"ORDER","B":[{"a":"USDT","wb":"100","cw":"90"}],"P":[{"s":"LDOUSDT","pa":"-3","ep":"2","up":"-0.1","mt":"isolated","iw":"5","ps":"BOTH"}]}}`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, USER_EVENT_ACCOUNT, event.Type)
+
+	account := event.Message.(models.AccountUpdate)
+	assert.Equal(t, "ORDER", account.Reason)
+	assert.Equal(t, "90", account.Balances[0].CrossWalletBalance)
+	assert.Equal(t, "-3", account.Positions[0].PositionAmt)
+	assert.Equal(t, "5", account.Positions[0].IsolatedMargin)
+
+	event, err = ParseUserEvent([]byte(`{"e":"MARGIN_CALL","E":1,"cw":"3.16","p":[{"s":"LDOUSDC","ps":"BOTH","pa":"1","mt":"cross","iw":"0","mp":"2","up":"-1","mm":"0.5"}]}`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, USER_EVENT_MARGIN_CALL, event.Type)
+
+	call := event.Message.(models.MarginCall)
+	assert.Equal(t, "3.16", call.CrossWalletBalance)
+	assert.Equal(t, "0.5", call.Positions[0].MaintMargin)
+
+	event, err = ParseUserEvent([]byte(`{"e":"TRADE_LITE"}`))
+
+	assert.NoError(t, err)
+	assert.Nil(t, event)
+
+	_, err = ParseUserEvent([]byte(`{"e":"listenKeyExpired"}`))
+
+	assert.Error(t, err)
+}
+
+func TestUserStream(t *testing.T) {
+	keys := 0
+	mutex := &sync.Mutex{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(BINANCE_FAPI_LISTEN_KEY, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "key", r.Header.Get("X-MBX-APIKEY"))
+
+		if r.Method == http.MethodPost {
+			mutex.Lock()
+			keys += 1
+			mutex.Unlock()
+		}
+
+		w.Write([]byte(`{"listenKey":"abc"}`))
+	})
+	mux.Handle("/ws/abc", websocket.Handler(func(conn *websocket.Conn) {
+		mutex.Lock()
+		count := keys
+		mutex.Unlock()
+
+		if count == 1 {
+			// expired keys make the stream reconnect with a new one
+			websocket.Message.Send(conn, `{"e":"listenKeyExpired"}`)
+			return
+		}
+
+		websocket.Message.Send(conn, testLiquidation)
+
+		var ignored string
+		websocket.Message.Receive(conn, &ignored)
+	}))
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	b := newTestBinance(server.URL)
+
+	subscription := NewUserSubscription(b)
+
+	stream := NewUserStream(b)
+	stream.Endpoint = "ws" + strings.TrimPrefix(server.URL, "http")
+	stream.MinBackoff = 10 * time.Millisecond
+	stream.subscribe(subscription)
+
+	go stream.Run()
+	defer stream.Close()
+
+	select {
+	case event := <-subscription.Events:
+		assert.Equal(t, USER_EVENT_ORDER, event.Type)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event received")
+	}
+
+	mutex.Lock()
+	assert.Equal(t, 2, keys)
+	mutex.Unlock()
+}
+
+func TestUserStreams(t *testing.T) {
+	closed := 0
+	ready := make(chan bool)
+	mutex := &sync.Mutex{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(BINANCE_FAPI_LISTEN_KEY, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			mutex.Lock()
+			closed += 1
+			mutex.Unlock()
+		}
+
+		w.Write([]byte(`{"listenKey":"abc"}`))
+	})
+	mux.Handle("/ws/abc", websocket.Handler(func(conn *websocket.Conn) {
+		<-ready
+
+		websocket.Message.Send(conn, testLiquidation)
+
+		var ignored string
+		websocket.Message.Receive(conn, &ignored)
+	}))
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	streams := NewUserStreams()
+	streams.Endpoint = "ws" + strings.TrimPrefix(server.URL, "http")
+
+	// two bots on the same account
+	first := NewUserSubscription(newTestBinance(server.URL))
+	first.streams = streams
+	second := NewUserSubscription(newTestBinance(server.URL))
+	second.streams = streams
+
+	first.Open()
+	second.Open()
+	close(ready)
+
+	assert.Len(t, streams.streams, 1)
+
+	for _, subscription := range []*UserSubscription{first, second} {
+		select {
+		case event := <-subscription.Events:
+			assert.Equal(t, USER_EVENT_ORDER, event.Type)
+		case <-time.After(2 * time.Second):
+			t.Fatal("no event received")
+		}
+	}
+
+	// the listenKey stays while a bot still listens
+	first.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	mutex.Lock()
+	assert.Equal(t, 0, closed)
+	mutex.Unlock()
+
+	second.Close()
+
+	assert.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return closed == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	assert.Empty(t, streams.streams)
+}
+
+func TestReceiveUserEvents(t *testing.T) {
+	exchange := &fakeExchange{
+		Positions: []models.BinanceOrder{
+			{Symbol: "LDOUSDT", PositionAmt: "-7"},
+			{Symbol: "LDOUSDC", PositionAmt: "10"},
+		},
+	}
+
+	core := newTestCore(exchange)
+	core.Provider = &fakeProvider{}
+	core.UserStream = NewUserSubscription(nil)
+	core.State = &models.CoreState{Step: 1, TotalQuantity: 0, MaxProgressBar: 10}
+
+	events := make([]models.EventMessage, 0)
+	done := make(chan bool)
+
+	go func() {
+		for v := range core.GetPublisher() {
+			events = append(events, v)
+		}
+
+		done <- true
+	}()
+
+	liquidation, _ := ParseUserEvent([]byte(testLiquidation))
+	other, _ := ParseUserEvent([]byte(`{"e":"ACCOUNT_UPDATE","E":1,"a":{"m":"ORDER","P":[{"s":"BTCUSDT","pa":"1"}]}}`))
+
+	core.UserStream.Events <- *liquidation
+	core.UserStream.Events <- *other
+
+	core.Tick(logrus.NewEntry(logrus.New()))
+
+	close(core.EventPublisher)
+	<-done
+
+	// the other bot's symbol is left out
+	assert.Equal(t, USER_EVENT_LIQUIDATION, events[0].Type)
+	assert.Equal(t, "repair", events[1].Type)
+
+	// the hedged leg is reduced instead of reopening the liquidated one
+	assert.Len(t, exchange.Orders, 1)
+	assert.Equal(t, "LDOUSDC", exchange.Orders[0].Symbol)
+	assert.Equal(t, "SELL", exchange.Orders[0].Side)
+	assert.Equal(t, "3", exchange.Orders[0].Quantity)
+	assert.Equal(t, "true", exchange.Orders[0].ReduceOnly)
+	assert.Equal(t, 7.0, core.Setting.Total)
+}
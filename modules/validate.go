@@ -0,0 +1,135 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/shopspring/decimal"
+)
+
+// InvalidSettingError means the setting can never trade, retrying won't help.
+type InvalidSettingError struct {
+	Reason string
+}
+
+func (e *InvalidSettingError) Error() string {
+	return e.Reason
+}
+
+func invalidSetting(format string, a ...any) error {
+	return &InvalidSettingError{Reason: fmt.Sprintf(format, a...)}
+}
+
+// GetSymbolInfos returns filters of every symbol Core places orders on.
+func (c *Core) GetSymbolInfos() ([]*models.BinanceSymbolInfo, error) {
+	infos := make([]*models.BinanceSymbolInfo, 0)
+
+	for _, symbol := range c.GetSymbols() {
+		info, err := c.Info.GetSymbol(symbol)
+		if err != nil {
+			return nil, err
+		}
+
+		infos = append(infos, info)
+	}
+
+	if c.Setting.Spot && c.SpotInfo != nil {
+		info, err := c.SpotInfo.GetSymbol(c.Setting.Symbol + c.Setting.PrimaryQuote)
+		if err != nil {
+			return nil, err
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+// RoundQuantity floors quantity to the lot size of every leg.
+func (c *Core) RoundQuantity(quantity float64) float64 {
+	infos, err := c.GetSymbolInfos()
+	if err != nil {
+		return quantity
+	}
+
+	value := decimal.NewFromFloat(quantity)
+
+	for _, info := range infos {
+		value = info.RoundQuantity(value)
+	}
+
+	rounded, _ := value.Float64()
+
+	return rounded
+}
+
+// ValidateSetting checks what needs no exchange, so bad settings fail before any request.
+func ValidateSetting(s *models.ConfigSetting) error {
+	switch {
+	case s.Symbol == "":
+		return invalidSetting("symbol is required")
+	case s.Quantity <= 0:
+		return invalidSetting("quantity must be greater than 0")
+	// arbitrage mode sets total to quantity itself, reduce mode closes less than quantity at the end
+	case !s.Arbitrage && !s.Reduce && s.Total < s.Quantity:
+		return invalidSetting("total must be greater than or equal to quantity")
+	case s.Leverage <= 0:
+		return invalidSetting("leverage must be greater than 0")
+	case !s.Spot && s.PrimaryQuote == s.SecondaryQuote:
+		return invalidSetting("primaryQuote and secondaryQuote must be different")
+	case s.Spot && s.DryRun:
+		return invalidSetting("dryRun doesn't support spot mode")
+	case s.RecvWindow < 0 || s.RecvWindow > MAX_RECV_WINDOW:
+		return invalidSetting("recvWindow must be between 0 and %d", MAX_RECV_WINDOW)
+	case s.Shutdown != "" && s.Shutdown != SHUTDOWN_KEEP && s.Shutdown != SHUTDOWN_UNWIND:
+		return invalidSetting("shutdown must be %s or %s", SHUTDOWN_KEEP, SHUTDOWN_UNWIND)
+	case s.ProtectAction != "" && s.ProtectAction != PROTECT_ACTION_REDUCE && s.ProtectAction != PROTECT_ACTION_MARGIN:
+		return invalidSetting("protectAction must be %s or %s", PROTECT_ACTION_REDUCE, PROTECT_ACTION_MARGIN)
+	}
+
+	return nil
+}
+
+// Validate checks the setting against exchange filters before trading.
+func (c *Core) Validate() error {
+	s := c.Setting
+
+	if err := ValidateSetting(s); err != nil {
+		return err
+	}
+
+	infos, err := c.GetSymbolInfos()
+	if err != nil {
+		return err
+	}
+
+	quantity := decimal.NewFromFloat(s.Quantity)
+	bid, _, _, _ := c.GetDepth(s.PrimaryQuote)
+
+	for _, info := range infos {
+		if info.Status != "TRADING" {
+			return invalidSetting("%s is not trading", info.Symbol)
+		}
+
+		rounded := info.RoundQuantity(quantity)
+
+		if rounded.IsZero() || rounded.LessThan(info.GetMinQty()) {
+			return invalidSetting("quantity %s is less than minQty %s of %s", quantity, info.GetMinQty(), info.Symbol)
+		}
+
+		notional := rounded.Mul(decimal.NewFromFloat(bid))
+
+		if bid > 0 && notional.LessThan(info.GetMinNotional()) {
+			return invalidSetting("notional %s is less than minNotional %s of %s", notional, info.GetMinNotional(), info.Symbol)
+		}
+	}
+
+	return nil
+}
+
+func IsInvalidSetting(err error) bool {
+	var invalid *InvalidSettingError
+
+	return errors.As(err, &invalid)
+}
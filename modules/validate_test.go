@@ -0,0 +1,67 @@
+package modules
+
+import (
+	"testing"
+
+	"github.com/CapsLock-Studio/binance-premium-bot/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestInfo(symbol string) models.BinanceSymbolInfo {
+	return models.BinanceSymbolInfo{
+		Symbol: symbol,
+		Status: "TRADING",
+		Filters: []models.BinanceFilter{
+			{FilterType: "LOT_SIZE", StepSize: "0.1", MinQty: "0.1"},
+			{FilterType: "MIN_NOTIONAL", Notional: "5"},
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	exchange := &fakeExchange{
+		Depth: map[string][4]float64{"LDOUSDT": {2, 100, 2.1, 100}},
+		Infos: []models.BinanceSymbolInfo{newTestInfo("LDOUSDT"), newTestInfo("LDOUSDC")},
+	}
+	core := newTestCore(exchange)
+	core.Setting.Leverage = 10
+	core.Setting.Quantity = 3.05
+
+	assert.NoError(t, core.Validate())
+	assert.Equal(t, 3.0, core.RoundQuantity(core.Setting.Quantity))
+
+	core.Setting.Quantity = 2
+	assert.True(t, IsInvalidSetting(core.Validate()))
+
+	core.Setting.Quantity = 3
+	core.Setting.RecvWindow = 70000
+	assert.EqualError(t, core.Validate(), "recvWindow must be between 0 and 60000")
+
+	core.Setting.RecvWindow = 0
+
+	core.Setting.Shutdown = "unwnd"
+	assert.EqualError(t, core.Validate(), "shutdown must be keep or unwind")
+
+	core.Setting.Shutdown = SHUTDOWN_UNWIND
+	assert.NoError(t, core.Validate())
+
+	core.Setting.ProtectAction = "close"
+	assert.EqualError(t, core.Validate(), "protectAction must be reduce or margin")
+
+	core.Setting.ProtectAction = PROTECT_ACTION_MARGIN
+	assert.NoError(t, core.Validate())
+
+	// arbitrage mode leaves total to the bot
+	core.Setting.Arbitrage = true
+	core.Setting.Total = 0
+	assert.NoError(t, core.Validate())
+
+	core.Setting.Arbitrage = false
+	assert.EqualError(t, core.Validate(), "total must be greater than or equal to quantity")
+
+	core.Setting.Total = 10
+
+	core.Setting.Quantity = 3
+	core.Setting.Symbol = "FOO"
+	assert.True(t, IsInvalidSetting(core.Validate()))
+}
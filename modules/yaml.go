@@ -1,28 +1,29 @@
 package modules
 
 import (
+	"context"
 	"io/ioutil"
 	"path/filepath"
 	"sync"
 
 	"github.com/CapsLock-Studio/binance-premium-bot/models"
-	"go.uber.org/ratelimit"
 	"gopkg.in/yaml.v2"
 )
 
 type Yaml struct {
-	Path        string
-	RateLimiter ratelimit.Limiter
+	Path    string
+	Limiter *Limiter
 }
 
-func NewYaml(path string, ratelimiter ratelimit.Limiter) *Yaml {
+func NewYaml(path string, limiter *Limiter) *Yaml {
 	return &Yaml{
-		Path:        path,
-		RateLimiter: ratelimiter,
+		Path:    path,
+		Limiter: limiter,
 	}
 }
 
-func (y *Yaml) Run() {
+// Run starts every bot of the config and waits for all of them, also after ctx is canceled.
+func (y *Yaml) Run(ctx context.Context) {
 	filename, _ := filepath.Abs(y.Path)
 	file, err := ioutil.ReadFile(filename)
 
@@ -34,9 +35,17 @@ func (y *Yaml) Run() {
 	config.Difference = DEFAULT_DIFFERENCE
 	config.Leverage = DEFAULT_LEVERAGE
 	config.Before = DEFAULT_MINUTES
+	config.PrimaryQuote = DEFAULT_PRIMARY_QUOTE
+	config.SecondaryQuote = DEFAULT_SECONDARY_QUOTE
+	config.ProtectAction = PROTECT_ACTION_REDUCE
+	config.RecvWindow = DEFAULT_RECV_WINDOW
+	config.Shutdown = SHUTDOWN_KEEP
 
 	yaml.Unmarshal(file, &config)
 
+	hub := NewHub(y.Limiter.Attach(NewBinance("", "")))
+	go hub.Run()
+
 	wg := &sync.WaitGroup{}
 
 	for _, setting := range config.Settings {
@@ -68,7 +77,43 @@ func (y *Yaml) Run() {
 				setting.Webhook = config.Webhook
 			}
 
-			NewCore(&setting, nil, nil, y.RateLimiter).Run()
+			if setting.PrimaryQuote == "" {
+				setting.PrimaryQuote = config.PrimaryQuote
+			}
+
+			if setting.SecondaryQuote == "" {
+				setting.SecondaryQuote = config.SecondaryQuote
+			}
+
+			if setting.Protect == 0 {
+				setting.Protect = config.Protect
+			}
+
+			if setting.ProtectAction == "" {
+				setting.ProtectAction = config.ProtectAction
+			}
+
+			if setting.FundingFeed == "" {
+				setting.FundingFeed = config.FundingFeed
+			}
+
+			if !setting.Stream {
+				setting.Stream = config.Stream
+			}
+
+			if !setting.UserStream {
+				setting.UserStream = config.UserStream
+			}
+
+			if setting.RecvWindow == 0 {
+				setting.RecvWindow = config.RecvWindow
+			}
+
+			if setting.Shutdown == "" {
+				setting.Shutdown = config.Shutdown
+			}
+
+			NewBinanceCore(&setting, nil, y.Limiter, hub).Run(ctx)
 		}(setting)
 	}
 